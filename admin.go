@@ -0,0 +1,176 @@
+package grmgr
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// AdminHandler returns the admin handler of the default grmgr service. See Manager.AdminHandler.
+func AdminHandler() http.Handler {
+	return Default().AdminHandler()
+}
+
+// AdminHandler returns an http.Handler to inspect and adjust the Limiters of m, e.g. mounted on an
+// existing mux using
+//
+//	mux.Handle("/grmgr/", http.StripPrefix("/grmgr", m.AdminHandler()))
+//
+// It serves
+//
+//	GET  /limiters                 status of all Limiters as JSON (see LimiterStatus)
+//	GET  /limiters/{name}          status of one Limiter
+//	POST /limiters/{name}/up       Up()
+//	POST /limiters/{name}/down     Down()
+//	POST /limiters/{name}/ceiling  SetCeiling(n), n given as a form or query value
+//	POST /limiters/{name}/pause    Pause()
+//	POST /limiters/{name}/resume   Resume()
+//	POST /up, /down                Up() or Down() all Limiters
+//	POST /pause, /resume           Pause() or Resume() all Limiters
+//
+// POST requests respond with the resulting status, or 503 Service Unavailable once grmgr is powered off.
+func (m *Manager) AdminHandler() http.Handler {
+	return &admin{m: m}
+}
+
+type admin struct {
+	m *Manager
+}
+
+func (a *admin) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+
+	path := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+
+	switch {
+	case len(path) == 1 && (path[0] == "up" || path[0] == "down" || path[0] == "pause" || path[0] == "resume"):
+		if r.Method != http.MethodPost {
+			a.error(w, http.StatusMethodNotAllowed, fmt.Errorf("%s requires POST", r.URL.Path))
+			return
+		}
+		var err error
+		switch path[0] {
+		case "up":
+			err = a.m.Up()
+		case "down":
+			err = a.m.Down()
+		case "pause":
+			err = a.m.Pause()
+		case "resume":
+			err = a.m.Resume()
+		}
+		if err != nil {
+			a.error(w, http.StatusServiceUnavailable, err)
+			return
+		}
+		a.reply(w, a.m.Status())
+
+	case path[0] == "limiters" && len(path) == 1:
+		if r.Method != http.MethodGet {
+			a.error(w, http.StatusMethodNotAllowed, fmt.Errorf("%s requires GET", r.URL.Path))
+			return
+		}
+		a.reply(w, a.m.Status())
+
+	case path[0] == "limiters" && len(path) == 2:
+		if r.Method != http.MethodGet {
+			a.error(w, http.StatusMethodNotAllowed, fmt.Errorf("%s requires GET", r.URL.Path))
+			return
+		}
+		a.limiter(w, path[1])
+
+	case path[0] == "limiters" && len(path) == 3:
+		if r.Method != http.MethodPost {
+			a.error(w, http.StatusMethodNotAllowed, fmt.Errorf("%s requires POST", r.URL.Path))
+			return
+		}
+		a.action(w, r, path[1], path[2])
+
+	default:
+		a.error(w, http.StatusNotFound, fmt.Errorf("%s not found", r.URL.Path))
+	}
+}
+
+func (a *admin) limiter(w http.ResponseWriter, name string) {
+	for _, s := range a.m.Status() {
+		if s.Name == Routine(name) {
+			a.reply(w, s)
+			return
+		}
+	}
+	a.error(w, http.StatusNotFound, fmt.Errorf("limiter %q not found", name))
+}
+
+func (a *admin) action(w http.ResponseWriter, r *http.Request, name, action string) {
+
+	select {
+	case <-a.m.powerOffCh:
+		// no Limiter is found once grmgr has stopped
+		a.error(w, http.StatusServiceUnavailable, ErrPoweredOff)
+		return
+	default:
+	}
+	l := a.m.Get(name)
+	if l == nil {
+		a.error(w, http.StatusNotFound, fmt.Errorf("limiter %q not found", name))
+		return
+	}
+
+	switch action {
+	case "up", "down", "pause", "resume":
+		var err error
+		switch action {
+		case "up":
+			err = l.Up()
+		case "down":
+			err = l.Down()
+		case "pause":
+			err = l.Pause()
+		case "resume":
+			err = l.Resume()
+		}
+		if err != nil {
+			a.actionError(w, http.StatusServiceUnavailable, err)
+			return
+		}
+	case "ceiling":
+		n, err := strconv.Atoi(r.FormValue("n"))
+		if err != nil {
+			a.error(w, http.StatusBadRequest, fmt.Errorf("ceiling requires an integer n: %w", err))
+			return
+		}
+		if err := l.SetCeiling(n); err != nil {
+			a.actionError(w, http.StatusBadRequest, err)
+			return
+		}
+	default:
+		a.error(w, http.StatusNotFound, fmt.Errorf("unknown action %q", action))
+		return
+	}
+	a.limiter(w, name)
+}
+
+// actionError replies with code, or not found if the Limiter was unregistered during the action.
+func (a *admin) actionError(w http.ResponseWriter, code int, err error) {
+	if errors.Is(err, ErrNotRegistered) {
+		code = http.StatusNotFound
+	}
+	a.error(w, code, err)
+}
+
+func (a *admin) reply(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		logErr(fmt.Errorf("admin: %w", err))
+	}
+}
+
+func (a *admin) error(w http.ResponseWriter, code int, err error) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(struct {
+		Error string `json:"error"`
+	}{err.Error()})
+}
@@ -0,0 +1,108 @@
+package grmgr
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestAdminHandler(t *testing.T) {
+	m, _ := startManager(t)
+	m.NewConfig("loader", 4, 1, 1, 1, "0s")
+	m.NewConfig("writer", 2, 1, 1, 1, "0s")
+
+	mux := http.NewServeMux()
+	mux.Handle("/grmgr/", http.StripPrefix("/grmgr", m.AdminHandler()))
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	var all []LimiterStatus
+	get(t, srv.URL+"/grmgr/limiters", http.StatusOK, &all)
+	if len(all) != 2 || all[0].Name != "loader" || all[0].Ceiling != 4 || all[1].Name != "writer" {
+		t.Fatalf("unexpected status %+v", all)
+	}
+
+	var st LimiterStatus
+	post(t, srv.URL+"/grmgr/limiters/loader/down", http.StatusOK, &st)
+	if st.Ceiling != 3 {
+		t.Errorf("expected ceiling 3 after down got %d", st.Ceiling)
+	}
+	post(t, srv.URL+"/grmgr/limiters/loader/ceiling?n=2", http.StatusOK, &st)
+	if st.Ceiling != 2 {
+		t.Errorf("expected ceiling 2 got %d", st.Ceiling)
+	}
+	post(t, srv.URL+"/grmgr/limiters/loader/ceiling?n=9", http.StatusBadRequest, nil)
+	post(t, srv.URL+"/grmgr/limiters/missing/up", http.StatusNotFound, nil)
+	get(t, srv.URL+"/grmgr/limiters/missing", http.StatusNotFound, nil)
+	post(t, srv.URL+"/grmgr/limiters", http.StatusMethodNotAllowed, nil)
+
+	post(t, srv.URL+"/grmgr/down", http.StatusOK, &all)
+	if all[0].Ceiling != 1 || all[1].Ceiling != 1 {
+		t.Errorf("expected all limiters throttled down got %+v", all)
+	}
+
+	post(t, srv.URL+"/grmgr/limiters/loader/pause", http.StatusOK, &st)
+	if !st.Paused {
+		t.Errorf("expected loader paused got %+v", st)
+	}
+	post(t, srv.URL+"/grmgr/pause", http.StatusOK, &all)
+	if !all[0].Paused || !all[1].Paused {
+		t.Errorf("expected all limiters paused got %+v", all)
+	}
+	post(t, srv.URL+"/grmgr/resume", http.StatusOK, &all)
+	if all[0].Paused || all[1].Paused || all[0].Ceiling != 1 {
+		t.Errorf("expected all limiters resumed at their ceiling got %+v", all)
+	}
+}
+
+func TestAdminPoweredOff(t *testing.T) {
+	m, cancel := startManager(t)
+	l := m.New("loader", 2)
+	srv := httptest.NewServer(m.AdminHandler())
+	defer srv.Close()
+	cancel()
+	<-m.powerOffCh
+
+	for _, path := range []string{"/up", "/down", "/pause", "/resume", "/limiters/loader/up", "/limiters/loader/ceiling?n=1"} {
+		post(t, srv.URL+path, http.StatusServiceUnavailable, nil)
+	}
+	if err := l.Up(); !errors.Is(err, ErrPoweredOff) {
+		t.Errorf("expected ErrPoweredOff from Up got %v", err)
+	}
+	if err := l.Down(); !errors.Is(err, ErrPoweredOff) {
+		t.Errorf("expected ErrPoweredOff from Down got %v", err)
+	}
+}
+
+func get(t *testing.T, url string, code int, v interface{}) {
+	t.Helper()
+	resp, err := http.Get(url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	decode(t, resp, code, v)
+}
+
+func post(t *testing.T, url string, code int, v interface{}) {
+	t.Helper()
+	resp, err := http.Post(url, "application/x-www-form-urlencoded", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	decode(t, resp, code, v)
+}
+
+func decode(t *testing.T, resp *http.Response, code int, v interface{}) {
+	t.Helper()
+	defer resp.Body.Close()
+	if resp.StatusCode != code {
+		t.Fatalf("%s: expected status %d got %d", resp.Request.URL, code, resp.StatusCode)
+	}
+	if v != nil {
+		if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
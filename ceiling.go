@@ -0,0 +1,122 @@
+package grmgr
+
+import (
+	"fmt"
+	"time"
+)
+
+type setOp byte
+
+const (
+	setCeiling setOp = iota
+	setBounds
+	setSteps
+	setPause
+	setResume
+)
+
+// setReq changes a limiter's configuration. grmgr responds on err.
+type setReq struct {
+	r    Routine
+	op   setOp
+	a, b int
+	err  chan error
+}
+
+// SetCeiling sets the ceiling (dop) to n, which must be within the limiter's minimum and maximum.
+// Unlike Up() and Down() the change is not subject to the hold period, but it does start a new one.
+// Waiting routines are granted slots immediately if the ceiling rises.
+func (l *Limiter) SetCeiling(n Ceiling) error {
+	return l.set(setCeiling, n, 0)
+}
+
+// SetBounds changes the minimum and maximum ceiling. The current ceiling is moved within the new bounds.
+func (l *Limiter) SetBounds(min, max Ceiling) error {
+	return l.set(setBounds, min, max)
+}
+
+// SetSteps changes the values by which Up() and Down() adjust the ceiling.
+func (l *Limiter) SetSteps(up, down int) error {
+	return l.set(setSteps, up, down)
+}
+
+func (l *Limiter) set(op setOp, a, b int) error {
+	req := setReq{r: l.r, op: op, a: a, b: b, err: make(chan error, 1)}
+	select {
+	case l.m.setCh <- req:
+	case <-l.m.powerOffCh:
+		return &ControlError{Limiter: l.r, Err: ErrPoweredOff}
+	}
+	return <-req.err
+}
+
+// apply a set request.
+// Called by grmgr only.
+func (l *Limiter) apply(req setReq, now time.Time) error {
+
+	switch req.op {
+
+	case setCeiling:
+		if req.a < l.minc || req.a > l.maxc {
+			return fmt.Errorf("SetCeiling: %d for %s is outside bounds [%d, %d]", req.a, l.or, l.minc, l.maxc)
+		}
+		if req.a < l.c {
+			l.throttleDownActioned = now
+		} else {
+			l.throttleUpActioned = now
+		}
+		logAlert(fmt.Sprintf("SetCeiling: %s ceiling set to %d [was: %d]", l.or, req.a, l.c))
+		l.setC(req.a)
+
+	case setBounds:
+		if req.a < 0 || req.b < 1 || req.a > req.b {
+			return fmt.Errorf("SetBounds: invalid bounds [%d, %d] for %s", req.a, req.b, l.or)
+		}
+		l.minc, l.maxc = req.a, req.b
+		logAlert(fmt.Sprintf("SetBounds: %s bounds set to [%d, %d]", l.or, l.minc, l.maxc))
+		switch {
+		case l.c < l.minc:
+			l.setC(l.minc)
+		case l.c > l.maxc:
+			l.setC(l.maxc)
+		}
+
+	case setSteps:
+		if req.a < 0 || req.b < 0 {
+			return fmt.Errorf("SetSteps: steps must not be negative, got up %d down %d for %s", req.a, req.b, l.or)
+		}
+		l.up, l.down = req.a, req.b
+		logAlert(fmt.Sprintf("SetSteps: %s steps set to [up: %d, down: %d]", l.or, l.up, l.down))
+
+	case setPause:
+		if l.paused {
+			return nil
+		}
+		l.paused = true
+		l.publish()
+		logAlert(fmt.Sprintf("Pause: %s paused [active: %d]", l.or, l.active()))
+
+	case setResume:
+		if !l.paused {
+			return nil
+		}
+		l.paused = false
+		l.publish()
+		l.release()
+		logAlert(fmt.Sprintf("Resume: %s resumed at ceiling %d", l.or, l.c))
+	}
+	return nil
+}
+
+// setC changes the ceiling, granting waiting routines if it has risen.
+// Called by grmgr only.
+func (l *Limiter) setC(c Ceiling) {
+	rise := c > l.c
+	l.metrics.changed(l.c, c)
+	l.c = c
+	l.publish()
+	l.scaleChildren()
+	if rise {
+		l.release()
+	}
+}
@@ -0,0 +1,103 @@
+package grmgr
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+// slots returns the number of slots TryControl can currently obtain, handing them back.
+func slots(l *Limiter) int {
+	n := 0
+	for l.TryControl() {
+		n++
+	}
+	for i := 0; i < n; i++ {
+		l.Done()
+	}
+	return n
+}
+
+func TestSetCeiling(t *testing.T) {
+	m, _ := startManager(t)
+	l, _ := m.NewConfig("set", 4, 1, 1, 1, "1h")
+
+	if err := l.SetCeiling(5); err == nil {
+		t.Error("expected error for ceiling above maximum")
+	}
+	if err := l.SetCeiling(1); err != nil {
+		t.Fatal(err)
+	}
+	if n := slots(l); n != 1 {
+		t.Fatalf("expected 1 slot got %d", n)
+	}
+
+	// a rising ceiling releases waiting routines without waiting for a Done()
+	l.Control()
+	granted := make(chan struct{})
+	go func() {
+		l.Control()
+		close(granted)
+	}()
+	time.Sleep(10 * time.Millisecond)
+	if err := l.SetCeiling(2); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-granted:
+	case <-time.After(time.Second):
+		t.Fatal("expected waiting routine released by SetCeiling")
+	}
+	l.Done()
+	l.Done()
+	l.Wait()
+}
+
+func TestSetBoundsAndSteps(t *testing.T) {
+	m, _ := startManager(t)
+	l, _ := m.NewConfig("bounds", 8, 1, 1, 1, "0s")
+
+	if err := l.SetBounds(3, 2); err == nil {
+		t.Error("expected error for min above max")
+	}
+	if err := l.SetBounds(2, 4); err != nil {
+		t.Fatal(err)
+	}
+	if n := slots(l); n != 4 {
+		t.Fatalf("expected ceiling clamped to 4 got %d", n)
+	}
+	if err := l.SetCeiling(1); err == nil {
+		t.Error("expected error for ceiling below minimum")
+	}
+
+	if err := l.SetSteps(-1, 1); err == nil {
+		t.Error("expected error for negative step")
+	}
+	if err := l.SetSteps(1, 2); err != nil {
+		t.Fatal(err)
+	}
+	l.Down()
+	if n := slots(l); n != 2 {
+		t.Fatalf("expected Down() by new step to 2 got %d", n)
+	}
+}
+
+func TestSetUnregistered(t *testing.T) {
+	m, _ := startManager(t)
+	l, _ := m.NewConfig("gone", 4, 1, 1, 1, "0s")
+	l.Unregister()
+
+	for name, err := range map[string]error{
+		"SetCeiling":   l.SetCeiling(1),
+		"SetBounds":    l.SetBounds(1, 2),
+		"SetSteps":     l.SetSteps(1, 1),
+		"Pause":        l.Pause(),
+		"Resume":       l.Resume(),
+		"ApplyProfile": l.ApplyProfile(""),
+		"AutoScale":    l.AutoScale(nil),
+	} {
+		if !errors.Is(err, ErrNotRegistered) {
+			t.Errorf("%s: expected ErrNotRegistered got %v", name, err)
+		}
+	}
+}
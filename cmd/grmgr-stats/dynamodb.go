@@ -0,0 +1,19 @@
+//go:build dynamodb
+// +build dynamodb
+
+package main
+
+import (
+	"context"
+	"sync"
+
+	"github.com/ros2hp/method-db/db"
+	"github.com/ros2hp/method-db/dynamodb"
+)
+
+func init() {
+	register = func(ctx context.Context, label, region string) {
+		var wg sync.WaitGroup
+		dynamodb.Register(ctx, label, &wg, db.Option{Name: "Region", Val: region})
+	}
+}
@@ -0,0 +1,132 @@
+// Command grmgr-stats lists the runs recorded by a grmgr stats sink, shows a run's statistics
+// for each Limiter and compares two runs.
+//
+//	grmgr-stats [flags] runs
+//	grmgr-stats [flags] show <run>
+//	grmgr-stats [flags] diff <run-a> <run-b>
+//
+// Statistics are read from the JSON lines file given by -file, otherwise from the database table
+// written by the "db" sink, which requires a build with the withstats and dynamodb tags.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/ros2hp/grmgr"
+)
+
+// register registers the database the table is read from. Set by the dynamodb build.
+var register func(ctx context.Context, label, region string)
+
+func main() {
+
+	var (
+		file    = flag.String("file", "", "JSON lines file written by the jsonl sink")
+		dbname  = flag.String("db", "default", "database of the db sink")
+		table   = flag.String("table", "runStats", "table of the db sink")
+		region  = flag.String("region", "us-east-1", "region of the database")
+		windows = flag.String("windows", "", "windows reported by the runs e.g. 10s,1m,1h (db sink only). Default grmgr's")
+		cols    = flag.String("columns", "", "comma separated statistics to show e.g. m1,m1_p95,m1_util. Default all")
+	)
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "usage: grmgr-stats [flags] runs | show <run> | diff <run-a> <run-b>\n")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	args := flag.Args()
+	if len(args) == 0 || len(args) != map[string]int{"runs": 1, "show": 2, "diff": 3}[args[0]] {
+		flag.Usage()
+		os.Exit(2)
+	}
+	r, err := reader(*file, *dbname, *table, *region, *windows)
+	if err != nil {
+		fatal(err)
+	}
+	var show map[string]bool
+	if len(*cols) > 0 {
+		show = make(map[string]bool)
+		for _, c := range strings.Split(*cols, ",") {
+			show[strings.TrimSpace(c)] = true
+		}
+	}
+	tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	defer tw.Flush()
+
+	switch args[0] {
+	case "runs":
+		runs, err := r.Runs()
+		if err != nil {
+			fatal(err)
+		}
+		for _, id := range runs {
+			fmt.Fprintln(tw, id)
+		}
+	case "show":
+		rs, err := r.Load(args[1])
+		if err != nil {
+			fatal(err)
+		}
+		fmt.Fprintln(tw, "limiter\tstatistic\tvalue")
+		for _, s := range rs {
+			for i, n := range s.Names {
+				if show == nil || show[n] {
+					fmt.Fprintf(tw, "%s\t%s\t%.3f\n", s.Limiter, n, s.Values[i])
+				}
+			}
+		}
+	case "diff":
+		a, err := r.Load(args[1])
+		if err != nil {
+			fatal(err)
+		}
+		b, err := r.Load(args[2])
+		if err != nil {
+			fatal(err)
+		}
+		fmt.Fprintln(tw, "limiter\tstatistic\ta\tb\tdelta")
+		for _, d := range grmgr.Diff(a, b) {
+			if show == nil || show[d.Name] {
+				fmt.Fprintf(tw, "%s\t%s\t%.3f\t%.3f\t%+.3f\n", d.Limiter, d.Name, d.A, d.B, d.Delta())
+			}
+		}
+	}
+}
+
+// reader returns the reader of the JSON lines file, or the database table.
+func reader(file, dbname, table, region, windows string) (grmgr.StatsReader, error) {
+
+	if len(file) > 0 {
+		return grmgr.NewJSONLReader(file), nil
+	}
+	var ws []time.Duration
+	if len(windows) > 0 {
+		for _, s := range strings.Split(windows, ",") {
+			w, err := time.ParseDuration(strings.TrimSpace(s))
+			if err != nil {
+				return nil, err
+			}
+			ws = append(ws, w)
+		}
+	}
+	r, err := grmgr.NewDBStatsReader(dbname, table, ws...)
+	if err != nil {
+		return nil, err
+	}
+	if register == nil {
+		return nil, fmt.Errorf("reading database %q requires the dynamodb build tag", dbname)
+	}
+	register(context.Background(), dbname, region)
+	return r, nil
+}
+
+func fatal(err error) {
+	fmt.Fprintln(os.Stderr, "grmgr-stats:", err)
+	os.Exit(1)
+}
@@ -0,0 +1,149 @@
+package grmgr
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+var (
+	// ErrName is returned when a Limiter has no name.
+	ErrName = errors.New("name must not be empty")
+	// ErrCeiling is returned when a Limiter's ceiling is less than one.
+	ErrCeiling = errors.New("ceiling must be greater than zero")
+	// ErrMin is returned when a Limiter's minimum ceiling is negative or above its ceiling.
+	ErrMin = errors.New("minimum must be between zero and the ceiling")
+	// ErrStep is returned when a Limiter's up or down step is negative.
+	ErrStep = errors.New("step must not be negative")
+	// ErrHold is returned when a Limiter's hold period is negative or cannot be parsed.
+	ErrHold = errors.New("invalid hold")
+	// ErrParent is returned when a Limiter's parent is not registered, or either is a fast Limiter.
+	ErrParent = errors.New("invalid parent")
+)
+
+// ConfigError reports an invalid Limiter configuration. Use errors.Is against ErrName, ErrCeiling,
+// ErrMin, ErrStep, ErrHold or ErrParent to determine the cause.
+type ConfigError struct {
+	Limiter string
+	Field   string
+	Err     error
+}
+
+func (e *ConfigError) Error() string {
+	return fmt.Sprintf("grmgr: limiter %q: %s: %s", e.Limiter, e.Field, e.Err)
+}
+
+func (e *ConfigError) Unwrap() error {
+	return e.Err
+}
+
+// LimiterConfig configures a Limiter. See Manager.NewLimiter.
+type LimiterConfig struct {
+	Name    string
+	Ceiling Ceiling       // initial ceiling, also the maximum
+	Min     Ceiling       // minimum ceiling
+	Up      int           // adjust current ceiling up by this value
+	Down    int           // adjust current ceiling down by this value
+	Hold    time.Duration // hold any change for this duration
+	Fast    bool          // lock free fast path (see NewFast)
+	Parent  string        // registered name of the Limiter whose budget this one shares, see Parent
+}
+
+// Validate returns a *ConfigError for the first invalid field, otherwise nil.
+func (c LimiterConfig) Validate() error {
+
+	cerr := func(field string, err error) error {
+		return &ConfigError{Limiter: c.Name, Field: field, Err: err}
+	}
+	switch {
+	case len(c.Name) == 0:
+		return cerr("name", ErrName)
+	case c.Ceiling < 1:
+		return cerr("ceiling", fmt.Errorf("%w, got %d", ErrCeiling, c.Ceiling))
+	case c.Min < 0 || c.Min > c.Ceiling:
+		return cerr("min", fmt.Errorf("%w, got %d with ceiling %d", ErrMin, c.Min, c.Ceiling))
+	case c.Up < 0:
+		return cerr("up", fmt.Errorf("%w, got %d", ErrStep, c.Up))
+	case c.Down < 0:
+		return cerr("down", fmt.Errorf("%w, got %d", ErrStep, c.Down))
+	case c.Hold < 0:
+		return cerr("hold", fmt.Errorf("%w, got %s", ErrHold, c.Hold))
+	case c.Fast && len(c.Parent) > 0:
+		return cerr("parent", fmt.Errorf("%w: a fast Limiter cannot have a parent", ErrParent))
+	}
+	return nil
+}
+
+// NewLimiter registers a Limiter with the default grmgr service. See Manager.NewLimiter.
+func NewLimiter(cfg LimiterConfig) (*Limiter, error) {
+	return Default().NewLimiter(cfg)
+}
+
+// NewLimiter validates cfg and registers the Limiter. An invalid configuration returns a *ConfigError,
+// and ErrPoweredOff is returned once grmgr has stopped.
+func (m *Manager) NewLimiter(cfg LimiterConfig) (*Limiter, error) {
+
+	if err := cfg.Validate(); err != nil {
+		logErr(err)
+		return nil, err
+	}
+	l := newLimiter(m, cfg)
+	if err := m.registerLimiter(l); err != nil {
+		logErr(err)
+		return nil, err
+	}
+	logAlert(fmt.Sprintf("New Routine %q  [%s] Ceiling: %d [min: %d, down: %d, up: %d, hold: %s]", cfg.Name, l.r, cfg.Ceiling, cfg.Min, cfg.Down, cfg.Up, cfg.Hold))
+	return l, nil
+}
+
+// newClamped registers a Limiter for cfg as New and NewFast did before configuration was validated:
+// a ceiling below 1 is taken as 0, which grants nothing, and the minimum is moved within [0, ceiling].
+// Unlike NewLimiter it never returns nil. A Limiter that could not be registered, because grmgr
+// has stopped, refuses every task.
+func (m *Manager) newClamped(cfg LimiterConfig) *Limiter {
+
+	if err := cfg.Validate(); err != nil {
+		logErr(err)
+	}
+	if cfg.Ceiling < 0 {
+		cfg.Ceiling = 0
+	}
+	if cfg.Min < 0 {
+		cfg.Min = 0
+	}
+	if cfg.Min > cfg.Ceiling {
+		cfg.Min = cfg.Ceiling
+	}
+	l := newLimiter(m, cfg)
+	if err := m.registerLimiter(l); err != nil {
+		logErr(err)
+		return l
+	}
+	logAlert(fmt.Sprintf("New Routine %q  [%s] Ceiling: %d [min: %d, down: %d, up: %d, hold: %s]", cfg.Name, l.r, cfg.Ceiling, cfg.Min, cfg.Down, cfg.Up, cfg.Hold))
+	return l
+}
+
+// registerLimiter registers l with grmgr, which assigns it a unique routine name.
+func (m *Manager) registerLimiter(l *Limiter) error {
+	select {
+	case m.registerCh <- l:
+	case <-m.powerOffCh:
+		return ErrPoweredOff
+	}
+	// wait for grmgr to assign a unique routine name
+	<-l.ch
+	return l.regErr
+}
+
+// newLimiter returns an unregistered Limiter for a valid cfg.
+func newLimiter(m *Manager, cfg LimiterConfig) *Limiter {
+
+	t0 := time.Now()
+
+	l := &Limiter{m: m, c: cfg.Ceiling, maxc: cfg.Ceiling, minc: cfg.Min, up: cfg.Up, down: cfg.Down, r: Routine(cfg.Name), or: Routine(cfg.Name), ch: make(chan struct{}), on: true, hold: cfg.Hold, fast: cfg.Fast, pname: Routine(cfg.Parent)}
+	l.fc.Store(int64(cfg.Ceiling))
+	l.throttleDownActioned = t0
+	l.throttleUpActioned = t0
+	l.areaT = t0
+	return l
+}
@@ -0,0 +1,85 @@
+package grmgr
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestLimiterConfigValidate(t *testing.T) {
+	valid := LimiterConfig{Name: "v", Ceiling: 4, Min: 1, Up: 1, Down: 2, Hold: time.Second}
+	if err := valid.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	for _, tc := range []struct {
+		field string
+		err   error
+		edit  func(c *LimiterConfig)
+	}{
+		{"name", ErrName, func(c *LimiterConfig) { c.Name = "" }},
+		{"ceiling", ErrCeiling, func(c *LimiterConfig) { c.Ceiling = 0 }},
+		{"min", ErrMin, func(c *LimiterConfig) { c.Min = 5 }},
+		{"min", ErrMin, func(c *LimiterConfig) { c.Min = -1 }},
+		{"up", ErrStep, func(c *LimiterConfig) { c.Up = -1 }},
+		{"down", ErrStep, func(c *LimiterConfig) { c.Down = -1 }},
+		{"hold", ErrHold, func(c *LimiterConfig) { c.Hold = -time.Second }},
+	} {
+		c := valid
+		tc.edit(&c)
+		err := c.Validate()
+		var cerr *ConfigError
+		if !errors.As(err, &cerr) || cerr.Field != tc.field || !errors.Is(err, tc.err) {
+			t.Errorf("%s: expected ConfigError wrapping %q got %v", tc.field, tc.err, err)
+		}
+	}
+}
+
+func TestNewConfigErrors(t *testing.T) {
+	m, _ := startManager(t)
+
+	l, err := m.NewConfig("badhold", 4, 1, 1, 1, "soon")
+	if l != nil || !errors.Is(err, ErrHold) {
+		t.Errorf("expected ErrHold got %v", err)
+	}
+	l, err = m.NewConfig("badmin", 4, 1, 1, 5, "1s")
+	if l != nil || !errors.Is(err, ErrMin) {
+		t.Errorf("expected ErrMin got %v", err)
+	}
+	// New and NewFast clamp rather than reject, as before validation was added
+	for _, l := range []*Limiter{m.New("zero", 0), m.NewFast("zero-fast", -1, 2)} {
+		if l == nil {
+			t.Fatal("expected Limiter for zero ceiling")
+		}
+		if l.TryControl() {
+			t.Errorf("%s: expected no slot at ceiling 0", l.Routine())
+		}
+	}
+	l, err = m.NewLimiter(LimiterConfig{Name: "ok", Ceiling: 2, Min: 1, Hold: time.Minute})
+	if err != nil || l == nil {
+		t.Fatalf("expected Limiter got %v", err)
+	}
+	if n := slots(l); n != 2 {
+		t.Errorf("expected ceiling 2 got %d", n)
+	}
+}
+
+func TestNewLimiterPoweredOff(t *testing.T) {
+	m, cancel := startManager(t)
+	cancel()
+	<-m.powerOffCh
+
+	if _, err := m.NewLimiter(LimiterConfig{Name: "late", Ceiling: 2, Min: 1}); !errors.Is(err, ErrPoweredOff) {
+		t.Errorf("expected ErrPoweredOff got %v", err)
+	}
+	l := m.New("late", 2)
+	if l == nil {
+		t.Fatal("expected Limiter after power off")
+	}
+	if err := l.ControlContext(context.Background()); !errors.Is(err, ErrPoweredOff) {
+		t.Errorf("expected ErrPoweredOff got %v", err)
+	}
+	// return rather than block
+	l.Unregister()
+	l.Delete()
+}
@@ -0,0 +1,252 @@
+package grmgr
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+// ConfigFile describes Limiters and scaling profiles in a JSON, YAML or TOML file, e.g. in YAML:
+//
+//	limiters:
+//	  - name: loader
+//	    ceiling: 40
+//	    min: 4
+//	    up: 1
+//	    down: 2
+//	    hold: 10s
+//	    profile: recover
+//	profiles:
+//	  - name: recover
+//	    steps:
+//	      - {factor: 0.5, hold: 30s}
+//	      - {delta: 1, hold: 30s, repeat: true}
+type ConfigFile struct {
+	Limiters []LimiterSpec `json:"limiters" yaml:"limiters" toml:"limiters"`
+	Profiles []ProfileSpec `json:"profiles" yaml:"profiles" toml:"profiles"`
+}
+
+// LimiterSpec is a Limiter in a ConfigFile. Omitted min, up, down and hold take the values used by New().
+// Profile names a scaling profile applied when the Limiter is loaded. Parent names the Limiter whose
+// budget it shares, which must be listed before it.
+type LimiterSpec struct {
+	Name    string `json:"name" yaml:"name" toml:"name"`
+	Ceiling int    `json:"ceiling" yaml:"ceiling" toml:"ceiling"`
+	Min     *int   `json:"min" yaml:"min" toml:"min"`
+	Up      *int   `json:"up" yaml:"up" toml:"up"`
+	Down    *int   `json:"down" yaml:"down" toml:"down"`
+	Hold    string `json:"hold" yaml:"hold" toml:"hold"`
+	Fast    bool   `json:"fast" yaml:"fast" toml:"fast"`
+	Profile string `json:"profile" yaml:"profile" toml:"profile"`
+	Parent  string `json:"parent" yaml:"parent" toml:"parent"`
+}
+
+// ProfileSpec is a scaling Profile in a ConfigFile.
+type ProfileSpec struct {
+	Name  string     `json:"name" yaml:"name" toml:"name"`
+	Steps []StepSpec `json:"steps" yaml:"steps" toml:"steps"`
+}
+
+// StepSpec is a Step in a ConfigFile.
+type StepSpec struct {
+	Factor float64 `json:"factor" yaml:"factor" toml:"factor"`
+	Delta  int     `json:"delta" yaml:"delta" toml:"delta"`
+	Set    int     `json:"set" yaml:"set" toml:"set"`
+	Hold   string  `json:"hold" yaml:"hold" toml:"hold"`
+	Repeat bool    `json:"repeat" yaml:"repeat" toml:"repeat"`
+}
+
+// config converts the spec to a LimiterConfig, validating it.
+func (s LimiterSpec) config() (LimiterConfig, error) {
+
+	cfg := LimiterConfig{Name: s.Name, Ceiling: s.Ceiling, Min: 1, Up: 1, Down: 2, Hold: 30 * time.Second, Fast: s.Fast, Parent: s.Parent}
+	if s.Min != nil {
+		cfg.Min = *s.Min
+	}
+	if s.Up != nil {
+		cfg.Up = *s.Up
+	}
+	if s.Down != nil {
+		cfg.Down = *s.Down
+	}
+	if len(s.Hold) > 0 {
+		h, err := time.ParseDuration(s.Hold)
+		if err != nil {
+			return cfg, &ConfigError{Limiter: s.Name, Field: "hold", Err: fmt.Errorf("%w: %s", ErrHold, err)}
+		}
+		cfg.Hold = h
+	}
+	return cfg, cfg.Validate()
+}
+
+// profile converts the spec to a Profile, validating it.
+func (s ProfileSpec) profile() (Profile, error) {
+
+	p := Profile{Name: s.Name}
+	for i, st := range s.Steps {
+		step := Step{Factor: st.Factor, Delta: st.Delta, Set: st.Set, Repeat: st.Repeat}
+		if len(st.Hold) > 0 {
+			h, err := time.ParseDuration(st.Hold)
+			if err != nil {
+				return p, fmt.Errorf("profile %q step %d: invalid hold: %w", s.Name, i, err)
+			}
+			step.Hold = h
+		}
+		p.Steps = append(p.Steps, step)
+	}
+	return p, p.validate()
+}
+
+// ReadConfigFile reads and decodes a ConfigFile. The format is determined by the file extension:
+// .json, .yaml, .yml or .toml.
+func ReadConfigFile(path string) (*ConfigFile, error) {
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var f ConfigFile
+
+	switch ext := strings.ToLower(filepath.Ext(path)); ext {
+	case ".json":
+		dec := json.NewDecoder(bytes.NewReader(data))
+		dec.DisallowUnknownFields()
+		err = dec.Decode(&f)
+	case ".yaml", ".yml":
+		dec := yaml.NewDecoder(bytes.NewReader(data))
+		dec.KnownFields(true)
+		err = dec.Decode(&f)
+	case ".toml":
+		var md toml.MetaData
+		md, err = toml.Decode(string(data), &f)
+		if err == nil && len(md.Undecoded()) > 0 {
+			err = fmt.Errorf("unknown key %q", md.Undecoded()[0].String())
+		}
+	default:
+		return nil, fmt.Errorf("config file %s: unsupported format %q", path, ext)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("config file %s: %w", path, err)
+	}
+	return &f, nil
+}
+
+// validate converts the file's definitions, returning the first error. Each limiter's profile must be
+// defined in the file or already registered, and its parent listed earlier in the file or already registered.
+// hasProfile and hasLimiter report what is already registered.
+func (f *ConfigFile) validate(hasProfile, hasLimiter func(string) bool) ([]LimiterConfig, []Profile, error) {
+
+	var (
+		cfgs  []LimiterConfig
+		profs []Profile
+		names = make(map[string]bool)
+	)
+	for _, ps := range f.Profiles {
+		p, err := ps.profile()
+		if err != nil {
+			return nil, nil, err
+		}
+		if names[p.Name] {
+			return nil, nil, fmt.Errorf("profile %q defined more than once", p.Name)
+		}
+		names[p.Name] = true
+		profs = append(profs, p)
+	}
+	pnames := names
+	names = make(map[string]bool)
+	for _, ls := range f.Limiters {
+		cfg, err := ls.config()
+		if err != nil {
+			return nil, nil, err
+		}
+		if names[cfg.Name] {
+			return nil, nil, fmt.Errorf("limiter %q defined more than once", cfg.Name)
+		}
+		if p := ls.Profile; len(p) > 0 && !pnames[p] && !hasProfile(p) {
+			return nil, nil, fmt.Errorf("limiter %q: profile %q not defined", cfg.Name, p)
+		}
+		if p := cfg.Parent; len(p) > 0 && !names[p] && !hasLimiter(p) {
+			return nil, nil, fmt.Errorf("limiter %q: parent %q must be listed before it or already registered", cfg.Name, p)
+		}
+		names[cfg.Name] = true
+		cfgs = append(cfgs, cfg)
+	}
+	return cfgs, profs, nil
+}
+
+// LoadConfig registers the Limiters and profiles of a config file with the default grmgr service.
+// See Manager.LoadConfig.
+func LoadConfig(path string) error {
+	return Default().LoadConfig(path)
+}
+
+// LoadConfig registers the profiles and Limiters defined in a config file (see ConfigFile).
+// The file is validated in full before anything is registered. Limiters are retrieved by name using Get().
+// A Limiter of the same name must not already be registered. grmgr must be running.
+func (m *Manager) LoadConfig(path string) error {
+
+	f, err := ReadConfigFile(path)
+	if err != nil {
+		return err
+	}
+	hasProfile := func(name string) bool {
+		return m.profile(profileReq{op: findProfile, name: name}) == nil
+	}
+	hasLimiter := func(name string) bool { return m.Get(name) != nil }
+
+	cfgs, profs, err := f.validate(hasProfile, hasLimiter)
+	if err != nil {
+		return fmt.Errorf("config file %s: %w", path, err)
+	}
+	for _, cfg := range cfgs {
+		if m.Get(cfg.Name) != nil {
+			return fmt.Errorf("config file %s: limiter %q already registered", path, cfg.Name)
+		}
+	}
+	for _, p := range profs {
+		if err := m.RegisterProfile(p); err != nil {
+			return err
+		}
+	}
+	for i, cfg := range cfgs {
+		l, err := m.NewLimiter(cfg)
+		if err != nil {
+			return err
+		}
+		if p := f.Limiters[i].Profile; len(p) > 0 {
+			if err := l.ApplyProfile(p); err != nil {
+				return fmt.Errorf("config file %s: limiter %q: %w", path, cfg.Name, err)
+			}
+		}
+	}
+	logAlert(fmt.Sprintf("LoadConfig: %s [limiters: %d, profiles: %d]", path, len(cfgs), len(profs)))
+	return nil
+}
+
+type getReq struct {
+	r    Routine
+	resp chan *Limiter
+}
+
+// Get returns the Limiter registered with the default grmgr service under name, or nil.
+func Get(name string) *Limiter {
+	return Default().Get(name)
+}
+
+// Get returns the Limiter registered under name, or nil.
+func (m *Manager) Get(name string) *Limiter {
+	req := getReq{r: Routine(name), resp: make(chan *Limiter, 1)}
+	select {
+	case m.getCh <- req:
+	case <-m.powerOffCh:
+		return nil
+	}
+	return <-req.resp
+}
@@ -0,0 +1,130 @@
+package grmgr
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func writeConfig(t *testing.T, name, content string) string {
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+var configFormats = map[string]string{
+	"limiters.yaml": `
+limiters:
+  - name: loader
+    ceiling: 8
+    min: 2
+    hold: 1h
+    profile: half
+  - name: writer
+    ceiling: 3
+profiles:
+  - name: half
+    steps:
+      - {factor: 0.5}
+`,
+	"limiters.json": `{
+	"limiters": [
+		{"name": "loader", "ceiling": 8, "min": 2, "hold": "1h", "profile": "half"},
+		{"name": "writer", "ceiling": 3}
+	],
+	"profiles": [{"name": "half", "steps": [{"factor": 0.5}]}]
+}`,
+	"limiters.toml": `
+[[limiters]]
+name = "loader"
+ceiling = 8
+min = 2
+hold = "1h"
+profile = "half"
+
+[[limiters]]
+name = "writer"
+ceiling = 3
+
+[[profiles]]
+name = "half"
+steps = [{factor = 0.5}]
+`,
+}
+
+func TestLoadConfig(t *testing.T) {
+	for name, content := range configFormats {
+		t.Run(name, func(t *testing.T) {
+			m, _ := startManager(t)
+			path := writeConfig(t, name, content)
+
+			if err := m.LoadConfig(path); err != nil {
+				t.Fatal(err)
+			}
+			loader, writer := m.Get("loader"), m.Get("writer")
+			if loader == nil || writer == nil {
+				t.Fatal("expected loaded limiters from Get")
+			}
+			if n := slots(loader); n != 4 {
+				t.Errorf("expected loader halved by profile to 4 got %d", n)
+			}
+			if n := slots(writer); n != 3 {
+				t.Errorf("expected writer ceiling 3 got %d", n)
+			}
+			if m.Get("missing") != nil {
+				t.Error("expected nil for unknown limiter")
+			}
+			if err := m.LoadConfig(path); err == nil {
+				t.Error("expected error loading limiters already registered")
+			}
+		})
+	}
+}
+
+func TestLoadConfigInvalid(t *testing.T) {
+	m, _ := startManager(t)
+
+	for name, tc := range map[string]struct {
+		content string
+		err     error
+	}{
+		"min.yaml":     {"limiters:\n  - {name: a, ceiling: 2, min: 3}\n", ErrMin},
+		"hold.json":    {`{"limiters": [{"name": "a", "ceiling": 2, "hold": "soon"}]}`, ErrHold},
+		"ceiling.toml": {"[[limiters]]\nname = \"a\"\n", ErrCeiling},
+		"unknown.yaml": {"limiters:\n  - {name: a, ceiling: 2, max: 3}\n", nil},
+		"dup.yaml":     {"limiters:\n  - {name: a, ceiling: 2}\n  - {name: a, ceiling: 2}\n", nil},
+		"limiters.ini": {"", nil},
+		"profile.yaml": {"limiters:\n  - {name: a, ceiling: 2}\n  - {name: c, ceiling: 2, profile: none}\n", nil},
+		"parent.yaml":  {"limiters:\n  - {name: a, ceiling: 2}\n  - {name: c, ceiling: 2, parent: d}\n  - {name: d, ceiling: 4}\n", nil},
+	} {
+		err := m.LoadConfig(writeConfig(t, name, tc.content))
+		if err == nil || (tc.err != nil && !errors.Is(err, tc.err)) {
+			t.Errorf("%s: expected error %v got %v", name, tc.err, err)
+		}
+		if m.Get("a") != nil {
+			t.Errorf("%s: expected nothing registered from an invalid file", name)
+		}
+	}
+}
+
+func TestLoadConfigRegistered(t *testing.T) {
+	m, _ := startManager(t)
+
+	if err := m.RegisterProfile(Profile{Name: "half", Steps: []Step{{Factor: 0.5}}}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.NewLimiter(LimiterConfig{Name: "pool", Ceiling: 8, Min: 1, Up: 1, Down: 2, Hold: time.Hour}); err != nil {
+		t.Fatal(err)
+	}
+	path := writeConfig(t, "limiters.yaml", "limiters:\n  - {name: a, ceiling: 8, hold: 1h, profile: half, parent: pool}\n")
+	if err := m.LoadConfig(path); err != nil {
+		t.Fatal(err)
+	}
+	if n := slots(m.Get("a")); n != 4 {
+		t.Errorf("expected a halved by profile to 4 got %d", n)
+	}
+}
@@ -0,0 +1,89 @@
+package grmgr
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+var (
+	// ErrCancelled is returned when the caller's context is cancelled before a slot is granted.
+	ErrCancelled = errors.New("control request cancelled")
+	// ErrTimedOut is returned when the caller's deadline expires before a slot is granted.
+	ErrTimedOut = errors.New("control request timed out")
+	// ErrPoweredOff is returned when the grmgr service has shutdown.
+	ErrPoweredOff = errors.New("grmgr is powered off")
+	// ErrShuttingDown is returned when the grmgr service is draining in-flight tasks before shutdown.
+	ErrShuttingDown = errors.New("grmgr is shutting down")
+	// ErrWeight is logged when ControlN or DoneN is called with a weight less than 1.
+	ErrWeight = errors.New("weight must be at least 1")
+	// ErrNotRegistered is returned when a Limiter is changed after it has been unregistered.
+	ErrNotRegistered = errors.New("limiter is not registered")
+)
+
+// ControlError reports why a Limiter did not grant a slot, or could not be changed. Use errors.Is
+// against ErrCancelled, ErrTimedOut, ErrShuttingDown, ErrPoweredOff or ErrNotRegistered to determine the cause.
+type ControlError struct {
+	Limiter Routine
+	Err     error
+}
+
+func (e *ControlError) Error() string {
+	return fmt.Sprintf("grmgr: limiter %s: %s", e.Limiter, e.Err)
+}
+
+func (e *ControlError) Unwrap() error {
+	return e.Err
+}
+
+// ControlContext is the context aware equivalent of Control(). It blocks until the limiter grants
+// a slot, the context is cancelled or its deadline expires, or grmgr is powered off.
+// Only a nil return grants a slot, in which case Done() must be called when the task finishes.
+func (l *Limiter) ControlContext(ctx context.Context) error {
+	return l.control(ctx, 1, 0)
+}
+
+// ControlTimeout is ControlContext() with a timeout of d.
+func (l *Limiter) ControlTimeout(d time.Duration) error {
+	ctx, cancel := context.WithTimeout(context.Background(), d)
+	defer cancel()
+	return l.ControlContext(ctx)
+}
+
+func (l *Limiter) ctxErr(ctx context.Context) error {
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return &ControlError{Limiter: l.r, Err: ErrTimedOut}
+	}
+	return &ControlError{Limiter: l.r, Err: ErrCancelled}
+}
+
+// tryAsk is a non-blocking ask. grmgr responds on resp with true if a slot was granted.
+type tryAsk struct {
+	r    Routine
+	resp chan bool
+}
+
+// TryControl grants a slot only if the limiter is under its ceiling now. It never waits for a slot
+// and is not counted as waiting, so it is suitable for pollers and select based event loops.
+// Done() must be called when the task finishes, if and only if TryControl returns true.
+func (l *Limiter) TryControl() bool {
+	l.metrics.asks.Add(1)
+	if l.fast {
+		return l.fastTry(1)
+	}
+	ask := tryAsk{r: l.r, resp: make(chan bool, 1)}
+	select {
+	case l.m.rTryAskCh <- ask:
+	case <-l.m.drainCh:
+		return false
+	case <-l.m.powerOffCh:
+		return false
+	}
+	return <-ask.resp
+}
+
+// TryAcquire aka TryControl()
+func (l *Limiter) TryAcquire() bool {
+	return l.TryControl()
+}
@@ -0,0 +1,72 @@
+package grmgr
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestControlContext(t *testing.T) {
+	m, _ := startManager(t)
+	l := m.New("ctx", 1)
+
+	l.Control()
+
+	err := l.ControlTimeout(10 * time.Millisecond)
+	if !errors.Is(err, ErrTimedOut) {
+		t.Errorf("expected ErrTimedOut got %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	err = l.ControlContext(ctx)
+	if !errors.Is(err, ErrCancelled) {
+		t.Errorf("expected ErrCancelled got %v", err)
+	}
+	var cerr *ControlError
+	if !errors.As(err, &cerr) || cerr.Limiter != l.Routine() {
+		t.Errorf("expected ControlError for %s got %v", l.Routine(), err)
+	}
+
+	l.Done()
+	// withdrawn asks must not be counted against the limiter
+	if err := l.ControlTimeout(time.Second); err != nil {
+		t.Fatalf("expected slot got %v", err)
+	}
+	l.Done()
+	l.Wait()
+}
+
+func TestControlContextPoweredOff(t *testing.T) {
+	m, cancel := startManager(t)
+	l := m.New("off", 1)
+	cancel()
+	<-m.powerOffCh
+
+	if err := l.ControlContext(context.Background()); !errors.Is(err, ErrPoweredOff) {
+		t.Errorf("expected ErrPoweredOff got %v", err)
+	}
+	if l.TryControl() {
+		t.Error("expected TryControl to fail once powered off")
+	}
+}
+
+func TestTryControl(t *testing.T) {
+	m, _ := startManager(t)
+	l := m.New("try", 2)
+
+	if !l.TryControl() || !l.TryAcquire() {
+		t.Fatal("expected two slots")
+	}
+	if l.TryControl() {
+		t.Fatal("expected no slot at ceiling")
+	}
+	l.Done()
+	if !l.TryControl() {
+		t.Fatal("expected slot after Done")
+	}
+	l.Done()
+	l.Done()
+	l.Wait()
+}
@@ -0,0 +1,76 @@
+package grmgr
+
+import (
+	"fmt"
+	"sort"
+)
+
+// Graceful shutdown. When the Config key "draintimeout" is set, cancelling the context passed to
+// PowerOn (or Run) does not stop grmgr immediately. Instead grmgr drains:
+//
+//   - routines waiting in, or calling, ControlContext() and ControlTimeout() are turned away with
+//     ErrShuttingDown and TryControl() returns false
+//   - routines waiting in, or calling, Control(), ControlN() and ControlPriority(), which cannot
+//     report that no slot was granted, wait until grmgr stops
+//   - tasks already granted a slot run to completion, calling Done() as usual
+//
+// until every Limiter is idle or the drain timeout expires, when the Limiters still running tasks
+// are logged by name. Only then does PowerOn return. Without a drain timeout grmgr stops immediately.
+// Either way Done() does not block once grmgr has stopped, and Wait() returns once every task
+// granted a slot, or left waiting by Control(), has called Done().
+
+// Stop halts admissions to all Limiters registered with m, as grmgr does when it drains on shutdown:
+// waiting and new asks are turned away with ErrShuttingDown while tasks already running finish.
+// grmgr keeps running, serving Done(), Status() etc., until its context is cancelled, so Control()
+// and the other variants that return no error wait until then. Stop cannot be undone; use Pause
+// to halt admissions temporarily.
+func (m *Manager) Stop() error {
+	ack := make(chan struct{})
+	select {
+	case m.stopCh <- ack:
+	case <-m.powerOffCh:
+		return ErrPoweredOff
+	}
+	<-ack
+	return nil
+}
+
+// drain stops admitting tasks and turns away waiting routines.
+// Called by grmgr only.
+func (m *Manager) drain() {
+
+	m.draining = true
+	close(m.drainCh)
+	for _, l := range m.rLimit {
+		for _, w := range l.waitq {
+			w.rejected, w.counted = true, false
+			l.wg.Done()
+		}
+		l.waitq = nil
+		for _, w := range l.fq {
+			w.rejected = true
+			l.fwait.Add(-1)
+		}
+		l.fq = nil
+		l.publish()
+	}
+}
+
+// idle reports whether no Limiter has a task running.
+// Called by grmgr only.
+func (m *Manager) idle() bool {
+	return len(m.stragglers()) == 0
+}
+
+// stragglers returns the Limiters with tasks running, by name, with the units they hold.
+// Called by grmgr only.
+func (m *Manager) stragglers() []string {
+	var s []string
+	for _, l := range m.rLimit {
+		if n := l.active(); n > 0 {
+			s = append(s, fmt.Sprintf("%s (%d)", l.r, n))
+		}
+	}
+	sort.Strings(s)
+	return s
+}
@@ -0,0 +1,220 @@
+package grmgr
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDrain(t *testing.T) {
+	for _, fast := range []bool{false, true} {
+		m, cancel := startManager(t, Config{"draintimeout": "5s"})
+		l, err := m.NewLimiter(LimiterConfig{Name: "loader", Ceiling: 1, Min: 1, Up: 1, Down: 1, Fast: fast})
+		if err != nil {
+			t.Fatal(err)
+		}
+		l.Control()
+
+		waiting := make(chan error)
+		go func() { waiting <- l.ControlContext(context.Background()) }()
+		time.Sleep(20 * time.Millisecond)
+
+		cancel()
+		if err := <-waiting; !errors.Is(err, ErrShuttingDown) {
+			t.Errorf("fast %v: expected waiting routine turned away with ErrShuttingDown got %v", fast, err)
+		}
+		if err := l.ControlTimeout(time.Second); !errors.Is(err, ErrShuttingDown) {
+			t.Errorf("fast %v: expected new ask turned away with ErrShuttingDown got %v", fast, err)
+		}
+		if l.TryControl() {
+			t.Errorf("fast %v: expected TryControl to fail while draining", fast)
+		}
+		select {
+		case <-m.powerOffCh:
+			t.Fatalf("fast %v: expected grmgr to wait for the running task", fast)
+		case <-time.After(50 * time.Millisecond):
+		}
+
+		l.Done()
+		select {
+		case <-m.powerOffCh:
+		case <-time.After(time.Second):
+			t.Fatalf("fast %v: expected grmgr to stop once drained", fast)
+		}
+	}
+}
+
+func TestDrainControl(t *testing.T) {
+	for _, fast := range []bool{false, true} {
+		m, cancel := startManager(t, Config{"draintimeout": "5s"})
+		l, _ := m.NewLimiter(LimiterConfig{Name: "loader", Ceiling: 1, Min: 1, Up: 1, Down: 1, Fast: fast})
+		l.Control()
+
+		// Control() cannot report it was turned away, so its caller runs the task and calls Done()
+		ended := make(chan struct{})
+		for i := 0; i < 2; i++ {
+			go func() {
+				l.Control()
+				l.Done()
+				ended <- struct{}{}
+			}()
+		}
+		time.Sleep(20 * time.Millisecond)
+		cancel()
+		time.Sleep(20 * time.Millisecond)
+		go func() {
+			l.ControlN(1)
+			l.DoneN(1)
+			ended <- struct{}{}
+		}()
+		select {
+		case <-ended:
+			t.Fatalf("fast %v: expected Control() to wait while draining", fast)
+		case <-time.After(50 * time.Millisecond):
+		}
+
+		l.Done()
+		<-m.powerOffCh
+		for i := 0; i < 3; i++ {
+			select {
+			case <-ended:
+			case <-time.After(time.Second):
+				t.Fatalf("fast %v: expected Control() to return once grmgr stopped", fast)
+			}
+		}
+		l.Wait()
+	}
+}
+
+func TestDrainTimeout(t *testing.T) {
+	m, cancel := startManager(t, Config{"draintimeout": "50ms"})
+	l, _ := m.NewConfig("loader", 2, 1, 1, 1, "0s")
+	l.Control()
+
+	t0 := time.Now()
+	cancel()
+	<-m.powerOffCh
+	if d := time.Since(t0); d < 50*time.Millisecond {
+		t.Errorf("expected grmgr to wait for the drain timeout, stopped after %s", d)
+	}
+	// Done does not block once grmgr has stopped
+	ended := make(chan struct{})
+	go func() {
+		l.Done()
+		close(ended)
+	}()
+	select {
+	case <-ended:
+	case <-time.After(time.Second):
+		t.Fatal("Done blocked after shutdown")
+	}
+}
+
+func TestStop(t *testing.T) {
+	m, _ := startManager(t)
+	l, _ := m.NewConfig("loader", 1, 1, 1, 1, "0s")
+	l.Control()
+
+	waiting := make(chan error)
+	go func() { waiting <- l.ControlContext(context.Background()) }()
+	time.Sleep(20 * time.Millisecond)
+
+	if err := m.Stop(); err != nil {
+		t.Fatal(err)
+	}
+	if err := <-waiting; !errors.Is(err, ErrShuttingDown) {
+		t.Errorf("expected waiting routine turned away with ErrShuttingDown got %v", err)
+	}
+	f := m.NewFast("writer", 2)
+	for _, l := range []*Limiter{l, f} {
+		if err := l.ControlTimeout(time.Second); !errors.Is(err, ErrShuttingDown) {
+			t.Errorf("%s: expected ErrShuttingDown after Stop got %v", l.Routine(), err)
+		}
+	}
+	st := m.Status()
+	if !st[0].Stopped || st[0].Active != 1 {
+		t.Errorf("expected loader stopped with its task running got %+v", st[0])
+	}
+	// running tasks still finish
+	l.Done()
+	if err := m.Stop(); err != nil {
+		t.Errorf("expected Stop to be repeatable got %v", err)
+	}
+	tbl := statusTable(m.Status())
+	if !strings.Contains(tbl, "LIMITER") || !strings.Contains(tbl, "loader") || !strings.Contains(tbl, "stopped") {
+		t.Errorf("unexpected status table\n%s", tbl)
+	}
+}
+
+func TestStopControl(t *testing.T) {
+	m, cancel := startManager(t)
+	l := m.New("loader", 2)
+	if err := m.Stop(); err != nil {
+		t.Fatal(err)
+	}
+
+	ended := make(chan struct{})
+	go func() {
+		l.Control()
+		l.Done()
+		close(ended)
+	}()
+	select {
+	case <-ended:
+		t.Fatal("expected Control() to wait after Stop")
+	case <-time.After(50 * time.Millisecond):
+	}
+	// grmgr still serves the Limiter
+	if st := m.Status(); len(st) != 1 || st[0].Active != 0 || !st[0].Stopped {
+		t.Errorf("unexpected status %+v", st)
+	}
+
+	cancel()
+	<-m.powerOffCh
+	select {
+	case <-ended:
+	case <-time.After(time.Second):
+		t.Fatal("expected Control() to return once grmgr stopped")
+	}
+	l.Wait()
+}
+
+func TestWaitAfterPowerOff(t *testing.T) {
+	for _, fast := range []bool{false, true} {
+		m, cancel := startManager(t)
+		l, _ := m.NewLimiter(LimiterConfig{Name: "loader", Ceiling: 1, Min: 1, Up: 1, Down: 1, Fast: fast})
+		l.Control()
+
+		// left waiting when grmgr stops: only Control() calls Done()
+		refused := make(chan error, 1)
+		go func() { refused <- l.ControlContext(context.Background()) }()
+		left := make(chan struct{})
+		go func() {
+			l.Control()
+			l.Done()
+			close(left)
+		}()
+		time.Sleep(20 * time.Millisecond)
+		cancel()
+		<-m.powerOffCh
+		if err := <-refused; !errors.Is(err, ErrPoweredOff) {
+			t.Errorf("fast %v: expected ErrPoweredOff got %v", fast, err)
+		}
+		<-left
+
+		// the task granted before shutdown finishes
+		l.Done()
+		waited := make(chan struct{})
+		go func() {
+			l.Wait()
+			close(waited)
+		}()
+		select {
+		case <-waited:
+		case <-time.After(time.Second):
+			t.Fatalf("fast %v: Wait() blocked after Done() once powered off", fast)
+		}
+	}
+}
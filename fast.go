@@ -0,0 +1,174 @@
+package grmgr
+
+import (
+	"context"
+	"time"
+)
+
+// A fast Limiter services Control() and Done() using atomic operations on the caller's goroutine
+// rather than a round trip to the grmgr goroutine. grmgr is only involved when a routine must wait
+// for a slot, to wake waiting routines and to change the ceiling (Up, Down).
+//
+// Waiting routines are granted slots in priority then arrival order, however a routine arriving
+// while slots are free takes one immediately, even if others are queued. Ask() and RespCh() are not supported.
+
+// NewFast registers a fast Limiter with the default grmgr service.
+func NewFast(r string, c Ceiling, min ...Ceiling) *Limiter {
+	return Default().NewFast(r, c, min...)
+}
+
+// NewFast registers a fast Limiter, using the same defaults, and clamping, as New().
+func (m *Manager) NewFast(r string, c Ceiling, min ...Ceiling) *Limiter {
+
+	mc := 1 // minimum ceiling
+	if len(min) > 0 {
+		mc = min[0]
+	}
+	return m.newClamped(LimiterConfig{Name: r, Ceiling: c, Min: mc, Up: 1, Down: 2, Hold: 30 * time.Second, Fast: true})
+}
+
+// fastAcquire claims n units if they fit under the published ceiling.
+func (l *Limiter) fastAcquire(n int64) bool {
+	for {
+		cnt, c := l.fcnt.Load(), l.fc.Load()
+		if c < 0 {
+			// not admitting
+			return false
+		}
+		// a weight above the ceiling is admitted when idle, though nothing is at a ceiling of 0
+		if cnt+n > c && !(cnt == 0 && n > c && c > 0) {
+			return false
+		}
+		if l.fcnt.CompareAndSwap(cnt, cnt+n) {
+			return true
+		}
+	}
+}
+
+func (l *Limiter) fastTry(n int) bool {
+	if !l.fastAcquire(int64(n)) {
+		return false
+	}
+	l.wg.Add(1)
+	return true
+}
+
+func (l *Limiter) fastControl(ctx context.Context, n int, p int) error {
+
+	if l.fastTry(n) {
+		return nil
+	}
+	// register as waiting before trying again, so a concurrent Done() knows to wake grmgr
+	l.fwait.Add(1)
+	if l.fastTry(n) {
+		l.fwait.Add(-1)
+		return nil
+	}
+
+	ask := &waiter{r: l.r, n: n, p: p, ch: make(chan struct{}, 1)}
+	select {
+	case l.m.fastAskCh <- ask:
+	case <-ctx.Done():
+		l.fwait.Add(-1)
+		return l.ctxErr(ctx)
+	case <-l.m.drainCh:
+		l.fwait.Add(-1)
+		return &ControlError{Limiter: l.r, Err: ErrShuttingDown}
+	case <-l.m.powerOffCh:
+		l.fwait.Add(-1)
+		return &ControlError{Limiter: l.r, Err: ErrPoweredOff}
+	}
+
+	select {
+	case <-ask.ch:
+		l.wg.Add(1)
+		return nil
+	case <-l.m.drainCh:
+		// units granted before draining started stand
+		select {
+		case <-ask.ch:
+			l.wg.Add(1)
+			return nil
+		default:
+		}
+		return &ControlError{Limiter: l.r, Err: ErrShuttingDown}
+	case <-ctx.Done():
+		// grmgr hands back the units if they were granted in the meantime
+		select {
+		case l.m.fastWithdrawCh <- ask:
+		case <-l.m.powerOffCh:
+		}
+		return l.ctxErr(ctx)
+	case <-l.m.powerOffCh:
+		return &ControlError{Limiter: l.r, Err: ErrPoweredOff}
+	}
+}
+
+func (l *Limiter) fastDone(n int) {
+
+	select {
+	case <-l.m.powerOffCh:
+		// as Done() of a Limiter served by grmgr
+		l.wg.Done()
+		return
+	default:
+	}
+	l.fcnt.Add(-int64(n))
+	l.fdone.Add(1)
+	l.metrics.completions.Add(1)
+	l.wg.Done()
+	// only one wake request is outstanding at a time. grmgr clears fwake before granting, so
+	// capacity freed after a pending request is still seen by it.
+	if l.fwait.Load() > 0 && l.fwake.CompareAndSwap(0, 1) {
+		select {
+		case l.m.fastWakeCh <- l.r:
+		case <-l.m.powerOffCh:
+		}
+	}
+}
+
+// fastRelease grants freed capacity to queued fast path waiters.
+// Called by grmgr only.
+func (l *Limiter) fastRelease() {
+	for len(l.fq) > 0 && l.fastAcquire(int64(l.fq[0].n)) {
+		w := l.fq.remove(0)
+		l.fwait.Add(-1)
+		w.ch <- struct{}{}
+	}
+}
+
+// fastWithdraw removes a waiter that gave up. If it had already been granted its units are released.
+// Called by grmgr only.
+func (l *Limiter) fastWithdraw(w *waiter) {
+	if w.rejected {
+		return
+	}
+	if i := l.fq.index(w); i >= 0 {
+		l.fq.remove(i)
+		l.fwait.Add(-1)
+		return
+	}
+	l.fcnt.Add(-int64(w.n))
+	l.fastRelease()
+}
+
+// publish makes a ceiling change visible to the fast path, granting queued waiters if it has risen.
+// Called by grmgr only.
+func (l *Limiter) publish() {
+	if l.fast {
+		c := int64(l.c)
+		if l.paused || l.m.draining {
+			c = -1
+		}
+		l.fc.Store(c)
+		l.fastRelease()
+	}
+}
+
+// active returns the units in use.
+func (l *Limiter) active() int {
+	if l.fast {
+		return int(l.fcnt.Load())
+	}
+	return l.rCnt
+}
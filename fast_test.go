@@ -0,0 +1,114 @@
+package grmgr
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestFastCeiling(t *testing.T) {
+	m, _ := startManager(t)
+	l := m.NewFast("fast", 3)
+
+	if peak := runTasks(l, 200, func(int) int { return 1 }); peak > 3 {
+		t.Errorf("expected at most 3 concurrent tasks got %d", peak)
+	}
+	if peak := runTasks(l, 100, func(i int) int { return 1 + i%3 }); peak > 3 {
+		t.Errorf("expected at most 3 units in use got %d", peak)
+	}
+}
+
+func TestFastControlTimeout(t *testing.T) {
+	m, _ := startManager(t)
+	l := m.NewFast("fast-timeout", 1)
+
+	l.Control()
+	if l.TryControl() {
+		t.Fatal("expected no slot at ceiling")
+	}
+	if err := l.ControlTimeout(10 * time.Millisecond); !errors.Is(err, ErrTimedOut) {
+		t.Errorf("expected ErrTimedOut got %v", err)
+	}
+	l.Done()
+	if err := l.ControlTimeout(time.Second); err != nil {
+		t.Fatalf("expected slot got %v", err)
+	}
+	l.Done()
+	l.Wait()
+}
+
+func TestFastDownUp(t *testing.T) {
+	m := NewManager()
+	l := &Limiter{m: m, c: 4, maxc: 4, minc: 1, up: 2, down: 2, fast: true}
+	l.fc.Store(4)
+
+	// grmgr side: lower the ceiling then queue a waiter above it
+	l.c = 2
+	l.publish()
+	if !l.fastTry(2) || l.fastTry(1) {
+		t.Fatal("expected fast path to honour lowered ceiling")
+	}
+	w := &waiter{n: 1, ch: make(chan struct{}, 1)}
+	l.fwait.Add(1)
+	l.fq = append(l.fq, w)
+
+	l.c = 4
+	l.publish()
+	select {
+	case <-w.ch:
+	default:
+		t.Fatal("expected raised ceiling to grant queued waiter")
+	}
+	if l.fwait.Load() != 0 || l.active() != 3 {
+		t.Errorf("expected 0 waiting and 3 active got %d and %d", l.fwait.Load(), l.active())
+	}
+}
+
+func TestFastCeilingZero(t *testing.T) {
+	l := &Limiter{m: NewManager(), c: 2, maxc: 2, fast: true}
+	l.fc.Store(2)
+
+	// an oversize weight is admitted while idle
+	if !l.fastTry(3) {
+		t.Fatal("expected oversize weight granted when idle")
+	}
+	l.fcnt.Store(0)
+
+	l.c = 0
+	l.publish()
+	for _, n := range []int{1, 3} {
+		if l.fastTry(n) {
+			t.Fatalf("expected no grant of weight %d at ceiling 0", n)
+		}
+	}
+}
+
+func benchmarkControlDone(b *testing.B, fast bool, c Ceiling) {
+	m := NewManager()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go m.Run(ctx)
+
+	var l *Limiter
+	if fast {
+		l = m.NewFast("bench", c)
+	} else {
+		l = m.New("bench", c)
+	}
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			l.Control()
+			l.Done()
+		}
+	})
+}
+
+func BenchmarkControlDone(b *testing.B) {
+	for _, c := range []Ceiling{4, 1024} {
+		b.Run(fmt.Sprintf("channel/c=%d", c), func(b *testing.B) { benchmarkControlDone(b, false, c) })
+		b.Run(fmt.Sprintf("fast/c=%d", c), func(b *testing.B) { benchmarkControlDone(b, true, c) })
+	}
+}
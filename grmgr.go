@@ -1,16 +1,20 @@
 //go:build withstats
 // +build withstats
 
+// The withstats edition must also be built with a method-db database tag, i.e. -tags withstats,dynamodb.
+
 package grmgr
 
 import (
-	"context"
+	"errors"
 	"fmt"
+	"reflect"
+	"sort"
 	"strings"
-	"sync"
 	"time"
 
 	"github.com/ros2hp/method-db/mut"
+	"github.com/ros2hp/method-db/query"
 	"github.com/ros2hp/method-db/tbl"
 	"github.com/ros2hp/method-db/tx"
 	"github.com/ros2hp/method-db/uuid"
@@ -21,592 +25,132 @@ const (
 	statsSystemTag string = "__grmgr"
 )
 
-type Routine = string
-
-type Ceiling = int
-
-type throttle_ byte
-
-func (t throttle_) Up() {
-	throttleUpCh <- Routine("__all")
+// dbSink merges each limiter's window statistics into a method-db table, one row per run and limiter
+// keyed by "run" and "sortk" ("gr#<limiter>"), with columns named by WindowStats.Values.
+type dbSink struct {
+	dbname string
+	reptbl string
 }
 
-func (t throttle_) Down() {
-	throttleDownCh <- Routine("__all")
+func newDBSink(dbname, reptbl string) (StatsSink, error) {
+	return &dbSink{dbname: dbname, reptbl: reptbl}, nil
 }
 
-func (t throttle_) Stop() {}
-
-func (t throttle_) String() {}
-
-var Control throttle_
-
-// Channels
-var (
-	EndCh          = make(chan Routine)
-	throttleDownCh = make(chan Routine)
-	throttleUpCh   = make(chan Routine)
-	//
-	rAskCh     = make(chan Routine)
-	rExpirehCh = make(chan Routine)
-)
-
-// Limiter
-type respCh chan struct{}
+func (d *dbSink) Write(runID string, limiter Routine, windows []WindowStats, samples []Sample) error {
 
-type Limiter struct {
-	r  Routine // modified routine to make unique
-	or Routine // original routine
-	//
-	c    Ceiling // ceiling value (starts at oc value)
-	maxc Ceiling // original (maximum) ceiling
-	minc Ceiling // minimum ceiling
-	//
-	up   int // scale up by value
-	down int // scale down by value (down <= up)
-	//
-	hold time.Duration // hold at current ceiling for duration
-	//
-	ch respCh
-	on bool // send Wait response
-	//
-	wg    sync.WaitGroup
-	rCnt  int // replace rCnt
-	rWait int // replace rWait
-	//
-	throttleDownActioned time.Time
-	throttleUpActioned   time.Time
-}
-
-func (l *Limiter) Ask() {
-	rAskCh <- l.r
-}
-
-// func (l *Limiter) StartR() {
-// 	//	StartCh <- l.r
-// }
-
-func (l *Limiter) EndR() {
-	EndCh <- l.r
-}
-
-func (l *Limiter) Done() {
-	EndCh <- l.r
-}
-
-func (l *Limiter) Unregister() {
-	unRegisterCh <- l.r
-}
-
-func (l *Limiter) Delete() {
-	unRegisterCh <- l.r
-}
-
-func (l Limiter) RespCh() respCh {
-	return l.ch
-}
-
-func (l Limiter) Control() {
-	rAskCh <- l.r
-	<-l.ch
-}
-
-// Wait for all groutine to finish i.e rCnt[l.r] == 0
-func (l *Limiter) Wait() {
-	l.wg.Wait()
-}
-
-func (l Limiter) Valve() {
-	l.Control()
-}
-
-func (l Limiter) Routine() Routine {
-	return l.r
-}
+	run, err := toUID(runID)
+	if err != nil {
+		return err
+	}
+	mtx := tx.New(statsSystemTag).DB(d.dbname)
 
-func (l Limiter) Up() {
-	throttleUpCh <- l.r
+	m := mtx.NewMerge(tbl.Name(d.reptbl)).AddMember("run", run, mut.IsKey).AddMember("sortk", "gr#"+limiter, mut.IsKey)
+	for _, w := range windows {
+		names, values := w.Values()
+		for i, c := range names {
+			m.AddMember(c, values[i])
+		}
+	}
+	return mtx.Execute()
 }
 
-func (l Limiter) Down() {
-	throttleDownCh <- l.r
+// toUID converts a run id to a uuid.UID, which panics on a malformed id.
+func toUID(runID string) (u uuid.UID, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("runid %q is not a uuid: %v", runID, r)
+		}
+	}()
+	return uuid.FromString(runID), nil
 }
 
-type rLimiterMap map[Routine]*Limiter
-
-var (
-	rLimit       rLimiterMap
-	registerCh   = make(chan *Limiter)
-	unRegisterCh = make(chan Routine)
-)
-
-//
-//
-//
-
-// Note: this package provides a slight enhancement to scaling goroutines the the channel buffer provides.
-// It is designed to throttle the number of running instances of a go Routine, i.e. it sets a ceiling on the number of concurrent goRoutines of a particular routine.
-// I cannot think of how to get the sync.WaitGroup to provide this feature. It is good for waiting on goRoutines to finish but
-// I don't know how to configure sync to set a ceiling on the number of concurrent goRoutines.
-
-// var eventCh chan struct{}{}
-
-//   main
-//   	eventCh=make(chan struct{}{},5)
-//   	for {
-//   		eventCh <- x  // the buffers will fill only if the receiptent of the message does not run a goroutine i.e. is synchronised. if the recipient is not a goroutine their will be only one process
-//                        // so to keep the main program from waiting for it to finish we include a buffer on the channel. Hopefully before the buffer fills the recipient will finish and
-//                        // execute again.
-//   	}                 // if the recipeient runs as go routine then the recipient will empty the buffer as fast as the main will fill it. This may lead to func X spawning a very large
-//                        //. number of goroutines the number of which are not impacted by the channel buffer size.
-//   }
-
-//   func_ X1
-//  	for e = range eventCh { // this will read from channel, start goRoutine and then read from channel again until it is closed
-//			go Routine          // The buffer will limit the number of active groutines. As one finishes this will free up a buffer slot and main will fill it with another request to be immediately read by X.
-//  	}
-//  }
-//   func_ X2
-//  	for e = range eventCh { // this will read from channel, start goRoutine and then read from channel again until it is closed
-//			Routine            // The buffer will limit the number of active groutines. As one finishes this will free up a buffer slot and main will fill it with another request to be immediately read by X.
-//  	}
-//  }
-//
-//   So channel buffers are not useful for recipients of channel events that execute go routines. They are useful when the recipient is synchronised with the execution.
-//    For goroutine recipients we need a mechanism that can throttle the running of goroutines. This package provides this service.
-//
-//   func_ Y
-//   	z := grmgr.New(<routine>, 5)
-//
-//   		for e = range eventCh
-//   			go Routine          // same as above, unlimited concurrent go routines run. go routine includes Start and End channel messages that increments & decrements internal counter.
-//				<-z.Wait()          //  grmgr will send event  on channel if there are less than Ceiling number of concurrent go routines.
-//   	}							// Note grmgr limit must be less than channel buffer. So set a large channel buffer and use grmgr to fluctuate between.
-//   }
-
-//   func_ Routine {
-
-//	}
-//
-// New registers a new routine and its ceiling (max concurrency) combination.
-func New(r string, c Ceiling, min ...Ceiling) *Limiter {
-
-	m := 1 // minimum ceiling
-	if len(min) > 0 {
-		m = min[0]
-	}
-	l, _ := NewConfig(r, c, 2, 1, m, "30s")
-	return l
+// dbReader reads the rows merged by dbSink.
+type dbReader struct {
+	dbname string
+	reptbl string
+	names  []string // columns read
 }
 
-//limitUnmarshaler := grmgr.NewConfig("unmarshaler", *concurrent*2, 2,1,3,"1m")
-
-// NewConfig - configure a Limiter throttle
-// r: limiter name
-// c: ceiling value (also the maximum value for the throttle)
-// down: adjust current ceiling down by specified value
-// up:   adjust current ceiling up by specified value
-// min: minimum value of ceiling
-// h: hold any change for this duration (in a string value that can be converted to time.Duration) e.g. "5s" for five seconds
-func NewConfig(r string, c Ceiling, down int, up int, min Ceiling, h string) (*Limiter, error) {
-
-	hold, err := time.ParseDuration(h)
-	if err != nil {
-		panic(err)
-		return nil, err
+// NewDBStatsReader returns a reader of the statistics written to table by the "db" sink. windows
+// are those reported by the run(s), by default the default windows.
+func NewDBStatsReader(dbname, table string, windows ...time.Duration) (StatsReader, error) {
+	if len(windows) == 0 {
+		windows = defaultWindows
 	}
-	t0 := time.Now()
-
-	l := Limiter{c: c, maxc: c, minc: min, up: up, down: down, r: Routine(r), or: Routine(r), ch: make(chan struct{}), on: true, hold: hold}
-	l.throttleDownActioned = t0
-	l.throttleUpActioned = t0
-	registerCh <- &l
-	logAlert(fmt.Sprintf("New Routine %q [%s] Ceiling: %d [min: %d, down: %d, up: %d, hold: %s]", r, l.r, c, min, down, up, h))
-	return &l, nil
-}
-
-var (
-	// take a snapshot of rCnt slice every snapInterval seconds - keep upto 2hrs worth of data
-	snapInterval = 2
-	// save to db every snapReportInterval (seconds)
-	snapReportInterval = 10
-	// keep live averages at the following reportInterval's (in seconds)
-	reportInterval          []int = []int{10, 20, 40, 60, 120, 180, 300, 600, 1200, 2400, 3600, 7200}
-	numSamplesAtRepInterval []int
-)
-
-func init() {
-	// prepopulate a useful metric used in calculation of averages
-	for _, v := range reportInterval {
-		numSamplesAtRepInterval = append(numSamplesAtRepInterval, v/snapInterval)
+	var names []string
+	for _, w := range windows {
+		n, _ := WindowStats{Column: column(w)}.Values()
+		names = append(names, n...)
 	}
+	return &dbReader{dbname: dbname, reptbl: table, names: names}, nil
 }
 
-// func scale(c int, perc float64) int {
-// 	oc := c
-// 	f := float64(c)
-// 	f *= perc
-// 	c = int(f)
-// 	if oc == c {
-// 		if perc > 1.0 {
-// 			c++
-// 		} else {
-// 			c--
-// 		}
-// 	}
-// 	return c
-// }
-
-// use channels to synchronise access to shared memory ie. the various maps, rLimiterMap.rCntMap.
-// "don't communicate by sharing memory, share memory by communicating"
-// grmgr runs as a single goroutine with sole access to the shared memory objects. Clients request or update data via channel requests.
-// TODO: keep adding entries to map. Determine when to purge entry from maps.
-type Config map[string]interface{}
+// Runs scans the table for the runs recorded, in uuid order.
+func (d *dbReader) Runs() ([]string, error) {
 
-func PowerOn(ctx context.Context, wpStart *sync.WaitGroup, wgEnd *sync.WaitGroup, cfg ...Config) {
-
-	defer wgEnd.Done()
-
-	var (
-		r Routine
-		l *Limiter
-		//snapshot reporting
-		s, rsnap int
-		ok       bool
-		dbname   string
-		reportOn bool
-		runId    uuid.UID
-		reptbl   string
-	)
-
-	if len(cfg) > 0 {
-		cfg := cfg[0]
-		for k, v := range cfg {
-			switch strings.ToLower(k) {
-			case "runid":
-				reportOn = true
-				runId, ok = v.(uuid.UID)
-				if !ok {
-					logErr(fmt.Errorf("runid should be a tx.uuid.UID"))
-				}
-			case "dbname":
-				reportOn = true
-				dbname = v.(string)
-			case "table":
-				reptbl = v.(string)
-			default:
-				logErr(fmt.Errorf("not a supported config key  %q", k))
-			}
-		}
-		if len(runId) == 0 {
-			logErr(fmt.Errorf("must supply a runid of uuid.UID type"))
-		}
-		if len(dbname) == 0 {
-			logAlert(`no database name specified in config. Will use "default"`)
-			dbname = "default"
-		}
-		if len(reptbl) == 0 {
-			logAlert(`no database name specified in config. Will use "runStats"`)
-			reptbl = "runStats"
-		}
+	var rows []struct {
+		Run   uuid.UID `dynamodbav:"run" mdb:"run"`
+		Sortk string   `dynamodbav:"sortk" mdb:"sortk"`
 	}
-
-	rLimit = make(rLimiterMap)
-	csnap := make(map[string][]int)  //cumlative snapshots
-	csnap_ := make(map[string][]int) //shadow copy of csnap used by reporting system
-
-	// throttleDownCh = make(chan struct{})
-	// throttleUpCh = make(chan struct{})
-
-	// setup snapshot interrupt goroutine
-	snapCh := make(chan time.Time)
-
-	ctxSnap, cancelSnap := context.WithCancel(context.Background())
-	var wgSnap sync.WaitGroup
-	var wgStart sync.WaitGroup
-	wgStart.Add(1)
-	wgSnap.Add(1)
-
-	// start report-snapshot goroutine
-	if reportOn {
-		go func() {
-			wgStart.Done()
-			defer wgSnap.Done()
-			// wait for grmgr to start for loop
-			wpStart.Wait()
-			logAlert("Report-snapshot Powering up...")
-			for {
-				select {
-				case t := <-time.After(time.Duration(snapInterval) * time.Second):
-					snapCh <- t
-				case <-ctxSnap.Done():
-					logAlert("Report-snapshot Shutdown.")
-					return
-				}
-			}
-
-		}()
-		logAlert("Waiting for gr monitor to power up...")
-		// wait for snap interrupter to start
-		wgStart.Wait()
+	q := tx.NewQuery(statsSystemTag, tbl.Name(d.reptbl)).DB(d.dbname)
+	q.Select(&rows)
+	if err := q.Execute(); err != nil && !errors.Is(err, query.NoDataFoundErr) {
+		return nil, err
 	}
-	logAlert("Started.")
-	wpStart.Done()
-
-	for {
-
-		select {
-
-		case l = <-registerCh:
-
-			// change the ceiling by passing in Limiter struct. As struct is a non-ref type, l is a copy of struct passed into channel. Ref typs, spcmf - slice, pointer, map, func, channel
-			// check not already registered -
-			// generate unique label
-			var e byte = 65
-			for {
-				if _, ok := rLimit[l.r]; !ok {
-					// unique label
-					break
-				}
-				// routine r already exists, generate a unique value
-				l.r += string(e)
-				e++
-				if e > 175 {
-					// generate a UUID instead
-					uid, _ := uuid.MakeUID()
-					l.r = uid.String()
-				}
-			}
-
-			rLimit[l.r] = l
-
-		case r = <-EndCh:
-
-			if l, ok := rLimit[r]; ok {
-				l.wg.Done()
-				l.rCnt--
-
-				if l.rWait > 0 && l.rCnt < l.c {
-					// Send ack to waiting routine
-					l.ch <- struct{}{}
-					l.rCnt++
-					l.rWait--
-				}
-
-			} else {
-				panic(fmt.Errorf("expected limiter %s in rLimit got nil", r))
-			}
-
-		case r = <-rAskCh:
-
-			if l, ok := rLimit[r]; ok {
-				l.wg.Add(1)
-
-				if l.rCnt < l.c {
-					// has ASKed
-					l.ch <- struct{}{} // proceed to run gr
-					l.rCnt++
-					logDebug(fmt.Sprintf("has ASKed. Under cnt limit. SEnt ACK on routine channel..for %s  cnt: %d Limit: %d", r, l.rCnt, l.c))
-				} else {
-					logDebug(fmt.Sprintf("has ASKed %s. Cnt [%d] is above limit [%d]. Mark %s as waiting", r, l.rCnt, l.c))
-					l.rWait++ // log routine as waiting to proceed
-				}
-
-			} else {
-				panic(fmt.Errorf("expected limiter %s in rLimit got nil", r))
-			}
-
-		case r = <-throttleDownCh:
-
-			allr := make(rLimiterMap)
-			if r == Routine("__all") {
-				allr = rLimit
-			} else {
-				allr[r] = rLimit[r]
-			}
-			t0 := time.Now()
-
-			for _, v := range allr {
-				//
-
-				if t0.Sub(v.throttleDownActioned) < v.hold {
-					logAlert("throttleDown: to soon to throttle down after last throttled action")
-				} else {
-
-					if t0.Sub(v.throttleUpActioned) < v.hold {
-						logAlert("throttleDown: to soon to throttle down after last throttled action")
-					} else {
-
-						// throttle down by 20%. Once changed cannot be modified for 2 minutes.
-
-						v.c -= v.down
-
-						if v.c < v.minc {
-							v.c = v.minc
-							logAlert(fmt.Sprintf("throttleDown: Throttling has reached minimum allowed [%d], for %s", v.c, v.minc))
-						} else {
-							logAlert(fmt.Sprintf("throttleDown: %s throttled down to %d [minimum: %d]", v.or, v.c, v.minc))
-						}
-					}
-				}
-				v.throttleDownActioned = t0
-			}
-
-		case r = <-throttleUpCh:
-
-			allr := make(rLimiterMap)
-			if r == Routine("__all") {
-				allr = rLimit
-			} else {
-				allr[r] = rLimit[r]
-			}
-			t0 := time.Now()
-
-			for _, v := range allr {
-				//
-
-				if t0.Sub(v.throttleDownActioned) < v.hold {
-					logAlert("throttleDown: to soon to throttle down after last throttled action")
-				} else {
-
-					if t0.Sub(v.throttleUpActioned) < v.hold {
-						logAlert("throttleDown: to soon to throttle down after last throttled action")
-					} else {
-
-						// throttle down by 20%. Once changed cannot be modified for 2 minutes.
-
-						v.c += v.up
-
-						if v.c > v.maxc {
-							v.c = v.maxc
-							logAlert(fmt.Sprintf("throttleDown: Throttling has reached minimum allowed [%d], for %s", v.c, v.minc))
-						} else {
-							logAlert(fmt.Sprintf("throttleDown: %s throttled down to %d [minimum: %d]", v.or, v.c, v.minc))
-						}
-					}
-				}
-
-				v.throttleUpActioned = t0
-			}
-
-		case <-snapCh:
-
-			s++
-			rsnap += snapInterval
-			//rsnap += snapInterval
-			// cumulate rCnt(one result per gr) into csnap (history)
-			for k, v := range rLimit {
-				csnap[k] = append(csnap[k], v.rCnt)
-			}
-			// save to db every snapReport seconds (default: 20s)
-			if rsnap == snapReportInterval {
-
-				// update shadow copy of csnap (csnap_) with latest results generated since last snap Report
-				// csnap_ is passed to reporting system to be read while csnap is being updated by time.After() - hence copy.
-				for k, v := range csnap {
-					if len(v) < s {
-						// not enough snapshots taken for limiter k - ignore for this report
-						continue
-					}
-					for _, vv := range v[len(v)-s:] {
-						csnap_[k] = append(csnap_[k], vv)
-					}
-				}
-				report(dbname, reptbl, csnap_, runId, snapInterval, snapReportInterval)
-				logDebug("gr dump report to table completed...")
-				rsnap, s = 0, 0
-
-			}
-
-		case r = <-unRegisterCh:
-
-			delete(rLimit, r)
-			delete(csnap, r)
-			logAlert(fmt.Sprintf("Unregister %s", r))
-
-		case <-ctx.Done():
-			cancelSnap()
-			logAlert("Waiting for internal snap service to shutdown...")
-			wgSnap.Wait()
-			logAlert("Internal snap service shutdown")
-			logAlert(fmt.Sprintf("Number of map entries not deleted: %d ", len(rLimit)))
-			for k, _ := range rLimit {
-				logAlert(fmt.Sprintf("rLimit Map Entry: %s", k))
-			}
-			// // TODO: Done should be in a separate select. If a request and Done occur simultaneously then go will randomly pick one.
-			// separating them means we have control. Is that the solution. Ideally we should control outside of uuid func().
-			logAlert("Shutdown.")
-			return
+	seen := make(map[string]bool)
+	var runs []string
+	for _, r := range rows {
+		if id := r.Run.String(); strings.HasPrefix(r.Sortk, "gr#") && !seen[id] {
+			seen[id] = true
+			runs = append(runs, id)
 		}
 	}
+	sort.Strings(runs)
+	return runs, nil
 }
 
-func report(dbname string, reptbl string, snap map[string][]int, runid uuid.UID, snapInterval, snapReportInterval int) {
-
-	// report average cnt for each interval for each grmgr limiter (throttler)
-	reportAvg := make(map[string]map[int]float64, len(snap))
-	// number of samples in a reporting interval (e.g. 10/2=5)
-	ns := numSamplesAtRepInterval
-
-	// populate reportAvg with map entries containing keys of sample size for each interval e.g. 10:5, 20:10, 40:20 for snapInterval of 2
-	for k, _ := range snap {
-
-		sample := make(map[int]float64, len(reportInterval))
-
-		for _, v := range reportInterval {
-			i := v / snapInterval
-			sample[i] = float64(0)
-
-		}
-		reportAvg[k] = sample
+// Load queries the run's rows. As the columns depend on the windows reported the rows are read
+// into a struct built from them.
+func (d *dbReader) Load(runID string) ([]RunStats, error) {
 
+	run, err := toUID(runID)
+	if err != nil {
+		return nil, err
 	}
-	for k, v := range snap {
-
-		ii, sum := 0, 0
-		// latest to oldest snapshot.
-		// terminate all entries after 2hrs =(2*3600)/snapInterval = 3600
-		for i := len(v); i > 0; i-- {
-
-			ii++
-			sum += v[i-1]
-
-			for kk, _ := range reportAvg[k] {
-
-				if kk == ii {
-					// calc average
-					reportAvg[k][kk] = float64(sum) / float64(kk)
-					break
-				}
-
-			}
-			// drop expired entries ie. > 2hrs
-			if ii == ns[len(ns)-1] {
-				logDebug("drop expired snap entries..")
-				snap[k] = v[1:]
-			}
+	fields := []reflect.StructField{{Name: "Sortk", Type: reflect.TypeOf(""), Tag: `dynamodbav:"sortk" mdb:"sortk"`}}
+	for i, n := range d.names {
+		fields = append(fields, reflect.StructField{
+			Name: fmt.Sprintf("V%d", i),
+			Type: reflect.TypeOf(float64(0)),
+			Tag:  reflect.StructTag(fmt.Sprintf(`dynamodbav:"%s" mdb:"%s"`, n, n)),
+		})
+	}
+	rows := reflect.New(reflect.SliceOf(reflect.StructOf(fields)))
 
+	q := tx.NewQuery(statsSystemTag, tbl.Name(d.reptbl)).DB(d.dbname)
+	q.Select(rows.Interface()).Key("run", run)
+	if err := q.Execute(); err != nil {
+		if errors.Is(err, query.NoDataFoundErr) {
+			return nil, fmt.Errorf("run %q not found", runID)
 		}
+		return nil, err
 	}
-	logDebug("About to dump report to table...")
-	// table columns in mon_gr containing averages
-	col := []string{"s10", "s20", "s40", "m1", "m2", "m3", "m5", "m10", "m20", "m40", "h1", "h2"}
-	// update database - this should be a merge opeation based on what key?
-
-	for k, v := range reportAvg {
-
-		mtx := tx.New(statsSystemTag).DB(dbname)
-
-		m := mtx.NewMerge(tbl.Name(reptbl)).AddMember("run", runid, mut.IsKey).AddMember("sortk", "gr#"+k, mut.IsKey)
-		for i, c := range col {
-			m.AddMember(c, v[ns[i]])
+	var rs []RunStats
+	for i, v := 0, rows.Elem(); i < v.Len(); i++ {
+		row := v.Index(i)
+		sk := row.Field(0).String()
+		if !strings.HasPrefix(sk, "gr#") {
+			continue
 		}
-		err := mtx.Execute()
-		if err != nil {
-			logErr(err)
+		s := RunStats{RunID: runID, Limiter: Routine(sk[3:]), Names: d.names, Values: make([]float64, len(d.names))}
+		for j := range d.names {
+			s.Values[j] = row.Field(j + 1).Float()
 		}
+		rs = append(rs, s)
 	}
-
-	//	}
-
+	if len(rs) == 0 {
+		return nil, fmt.Errorf("run %q not found", runID)
+	}
+	sort.Slice(rs, func(i, j int) bool { return rs[i].Limiter < rs[j].Limiter })
+	return rs, nil
 }
@@ -0,0 +1,125 @@
+package grmgr
+
+import (
+	"sort"
+	"time"
+)
+
+// history holds a Limiter's most recent samples in a ring buffer, sized to the longest window
+// (or report interval), together with a running total of each window's samples, so a snapshot
+// updates every window's mean in O(1) and memory is bounded however long the run. Percentiles
+// are computed from the window's samples when a report is written.
+type history struct {
+	buf    []Sample // grows to cap, then wraps
+	cap    int
+	oldest int      // index of the oldest sample once buf is full
+	totals []totals // running total of the latest nsamples[i] samples
+}
+
+// totals of a window's samples. Kept as integers so adding and removing samples does not drift.
+type totals struct {
+	active, waiting, ceiling, completed int64
+	wait                                time.Duration
+}
+
+func (t *totals) add(s *Sample, sign int64) {
+	t.active += sign * int64(s.Active)
+	t.waiting += sign * int64(s.Waiting)
+	t.ceiling += sign * int64(s.Ceiling)
+	t.completed += sign * int64(s.Completed)
+	t.wait += time.Duration(sign) * s.Wait
+}
+
+func newHistory(size, windows int) *history {
+	return &history{cap: size, totals: make([]totals, windows)}
+}
+
+// len returns the number of samples held.
+func (h *history) len() int {
+	return len(h.buf)
+}
+
+// at returns the i'th oldest sample held.
+func (h *history) at(i int) *Sample {
+	return &h.buf[(h.oldest+i)%len(h.buf)]
+}
+
+// add appends s, dropping the oldest sample when full, and moves each window of nsamples along by one.
+func (h *history) add(s Sample, nsamples []int) {
+	n := len(h.buf)
+	for i, ns := range nsamples {
+		h.totals[i].add(&s, 1)
+		if n >= ns {
+			// sample leaving the window
+			h.totals[i].add(h.at(n-ns), -1)
+		}
+	}
+	if n < h.cap {
+		h.buf = append(h.buf, s)
+		return
+	}
+	h.buf[h.oldest] = s
+	h.oldest = (h.oldest + 1) % h.cap
+}
+
+// last returns a copy of the latest k samples, oldest first.
+func (h *history) last(k int) []Sample {
+	if h == nil {
+		return nil
+	}
+	if k > len(h.buf) {
+		k = len(h.buf)
+	}
+	s := make([]Sample, k)
+	for i := range s {
+		s[i] = *h.at(len(h.buf) - k + i)
+	}
+	return s
+}
+
+// window summarises the latest ns samples, the i'th window. A window that is not yet full
+// has zero summaries. Only the percentiles read the samples, which are sorted for the purpose.
+func (h *history) window(i int, w time.Duration, column string, ns int) WindowStats {
+	ws := WindowStats{Window: w, Column: column}
+	if len(h.buf) < ns {
+		return ws
+	}
+	ordered := func(v func(s *Sample) float64) []float64 {
+		vs := make([]float64, ns)
+		for j := range vs {
+			vs[j] = v(h.at(len(h.buf) - ns + j))
+		}
+		sort.Float64s(vs)
+		return vs
+	}
+	t := h.totals[i]
+	ws.Active = summarize(ordered(func(s *Sample) float64 { return float64(s.Active) }), float64(t.active))
+	ws.Waiting = summarize(ordered(func(s *Sample) float64 { return float64(s.Waiting) }), float64(t.waiting))
+	ws.Ceiling = summarize(ordered(func(s *Sample) float64 { return float64(s.Ceiling) }), float64(t.ceiling))
+	ws.Completed = summarize(ordered(func(s *Sample) float64 { return float64(s.Completed) }), float64(t.completed))
+	ws.Wait = summarize(ordered(func(s *Sample) float64 { return s.Wait.Seconds() }), t.wait.Seconds())
+	return ws
+}
+
+type historyReq struct {
+	r    Routine
+	resp chan []Sample
+}
+
+// History returns the samples held for the named Limiter of the default Manager.
+func History(name string) []Sample {
+	return Default().History(name)
+}
+
+// History returns the samples held for the named Limiter, oldest first: one every snap interval
+// covering the longest window. Samples are only taken when a stats sink is configured or the
+// Config key "history" is true. Returns nil for an unknown Limiter, or when no samples are taken.
+func (m *Manager) History(name string) []Sample {
+	req := historyReq{r: Routine(name), resp: make(chan []Sample, 1)}
+	select {
+	case m.historyCh <- req:
+	case <-m.powerOffCh:
+		return nil
+	}
+	return <-req.resp
+}
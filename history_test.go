@@ -0,0 +1,75 @@
+package grmgr
+
+import (
+	"testing"
+	"time"
+)
+
+func TestHistoryRing(t *testing.T) {
+	nsamples := []int{2, 3, 5}
+	h := newHistory(5, len(nsamples))
+
+	for i := 1; i <= 12; i++ {
+		h.add(Sample{Active: i, Completed: 2 * i, Wait: time.Duration(i) * time.Second}, nsamples)
+
+		want := i
+		if want > 5 {
+			want = 5
+		}
+		if h.len() != want {
+			t.Fatalf("sample %d: holding %d samples", i, h.len())
+		}
+		// running totals match the window's samples
+		for w, ns := range nsamples {
+			if h.len() < ns {
+				continue
+			}
+			var active, completed int64
+			for _, s := range h.last(ns) {
+				active += int64(s.Active)
+				completed += int64(s.Completed)
+			}
+			if tot := h.totals[w]; tot.active != active || tot.completed != completed {
+				t.Fatalf("sample %d window %d: totals %+v, expected active %d completed %d", i, ns, tot, active, completed)
+			}
+		}
+	}
+	if s := h.last(10); len(s) != 5 || s[0].Active != 8 || s[4].Active != 12 {
+		t.Errorf("expected samples 8 to 12 oldest first got %+v", s)
+	}
+	ws := h.window(1, 3*time.Second, "s3", 3)
+	if ws.Active.Mean != 11 || ws.Active.Min != 10 || ws.Active.Max != 12 || ws.Active.P50 != 11 || ws.Wait.Mean != 11 {
+		t.Errorf("unexpected window %+v", ws)
+	}
+	if ws := newHistory(5, 1).window(0, time.Second, "s1", 2); ws.Active != (Summary{}) {
+		t.Errorf("expected zero summary for a window not yet full got %+v", ws)
+	}
+}
+
+func TestHistory(t *testing.T) {
+	m, _ := startManager(t, Config{"snapinterval": "10ms", "reportinterval": "10ms", "windows": []string{"20ms", "50ms"}, "history": true})
+	m.NewConfig("loader", 4, 1, 1, 1, "1h")
+
+	time.Sleep(300 * time.Millisecond)
+	h := m.History("loader")
+	if len(h) != 5 {
+		t.Fatalf("expected the longest window's 5 samples got %d", len(h))
+	}
+	for i, s := range h {
+		if s.Ceiling != 4 || i > 0 && !s.Time.After(h[i-1].Time) {
+			t.Errorf("unexpected samples %+v", h)
+			break
+		}
+	}
+	if h := m.History("writer"); h != nil {
+		t.Errorf("expected no history for an unknown limiter got %+v", h)
+	}
+
+	// no snapshots are taken without a sink or history
+	m, _ = startManager(t, Config{"snapinterval": "10ms", "reportinterval": "10ms", "windows": []string{"20ms"}})
+	m.NewConfig("loader", 4, 1, 1, 1, "1h")
+	time.Sleep(50 * time.Millisecond)
+	if h := m.History("loader"); h != nil {
+		t.Errorf("expected no history got %+v", h)
+	}
+}
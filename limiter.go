@@ -0,0 +1,244 @@
+package grmgr
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+type Routine = string
+
+type Ceiling = int
+
+// Limiter
+type respCh chan struct{}
+
+type Limiter struct {
+	m *Manager // grmgr service the limiter is registered with
+	//
+	r  Routine // modified routine to make unique
+	or Routine // original routine
+	//
+	c    Ceiling // ceiling value (starts at oc value)
+	maxc Ceiling // original (maximum) ceiling
+	minc Ceiling // minimum ceiling
+	//
+	up   int // scale up by value
+	down int // scale down by value (down <= up)
+	//
+	hold time.Duration // hold at current ceiling for duration
+	//
+	paused bool // no slots granted, see Pause
+	//
+	pname    Routine    // parent requested at registration
+	parent   *Limiter   // budget shared with siblings, see Parent
+	children []*Limiter // Limiters sharing this one's budget
+	next     int        // Limiter of the tree to be granted first, at the topmost parent
+	regErr   error      // registration failure, set by grmgr
+	//
+	ch respCh
+	on bool // send Wait response
+	//
+	wg     sync.WaitGroup
+	rCnt   int       // units in use
+	waitq  waitQueue // waiting routines, ordered by priority then arrival
+	bypass int       // number of grants that have overtaken the head of waitq
+	//
+	fast  bool         // lock free fast path (see NewFast)
+	fc    atomic.Int64 // ceiling published to the fast path, -1 when not admitting
+	fcnt  atomic.Int64 // units in use on the fast path
+	fwait atomic.Int64 // routines waiting on the fast path
+	fwake atomic.Int32 // wake request pending
+	fdone atomic.Int64 // tasks completed on the fast path
+	fq    waitQueue    // fast path waiters, maintained by grmgr
+	//
+	scaler    Scaler      // automatic ceiling adjustment
+	prof      *profileRun // scaling profile in progress
+	completed int         // tasks completed since last scaling observation
+	area      float64     // units in use integrated over time (unit seconds) since last observation
+	areaT     time.Time   // time of last change to rCnt
+	//
+	throttleDownActioned time.Time
+	throttleUpActioned   time.Time
+	//
+	metrics counters
+	sampled struct{ completions, waits, waitSum int64 } // counters at last stats sample
+}
+
+func (l *Limiter) Ask() {
+	l.metrics.asks.Add(1)
+	select {
+	case l.m.rAskCh <- l.r:
+	case <-l.m.powerOffCh:
+	}
+}
+
+// func (l *Limiter) StartR() {
+// 	//	StartCh <- l.r
+// }
+
+func (l *Limiter) EndR() {
+	l.Done()
+}
+
+func (l *Limiter) Done() {
+	if l.fast {
+		l.fastDone(1)
+		return
+	}
+	select {
+	case l.m.endCh <- l.r:
+	case <-l.m.powerOffCh:
+		// grmgr no longer keeps count, but Wait() still does
+		l.wg.Done()
+	}
+}
+
+func (l *Limiter) Unregister() {
+	select {
+	case l.m.unRegisterCh <- l.r:
+	case <-l.m.powerOffCh:
+		// nothing is registered once grmgr has powered off
+	}
+}
+
+func (l *Limiter) Delete() {
+	select {
+	case l.m.unRegisterCh <- l.r:
+	case <-l.m.powerOffCh:
+		// nothing is registered once grmgr has powered off
+	}
+}
+
+func (l *Limiter) RespCh() respCh {
+	return l.ch
+}
+
+func (l *Limiter) Control() {
+	l.controlWait(1, 0)
+}
+
+// Wait for all groutine to finish i.e rCnt[l.r] == 0
+func (l *Limiter) Wait() {
+	l.wg.Wait()
+}
+
+func (l *Limiter) Valve() {
+	l.Control()
+}
+
+func (l *Limiter) Routine() Routine {
+	return l.r
+}
+
+// Up throttles the Limiter up by its up step. Returns an error once grmgr is powered off.
+func (l *Limiter) Up() error {
+	return l.throttle(l.m.throttleUpCh)
+}
+
+// Down throttles the Limiter down by its down step. Returns an error once grmgr is powered off.
+func (l *Limiter) Down() error {
+	return l.throttle(l.m.throttleDownCh)
+}
+
+func (l *Limiter) throttle(ch chan throttleReq) error {
+	select {
+	case ch <- throttleReq{r: l.r}:
+	case <-l.m.powerOffCh:
+		return &ControlError{Limiter: l.r, Err: ErrPoweredOff}
+	}
+	return nil
+}
+
+type rLimiterMap map[Routine]*Limiter
+
+//
+//
+
+// Note: this package provides a slight enhancement to scaling goroutines the the channel buffer provides.
+// It is designed to throttle the number of running instances of a go Routine, i.e. it sets a ceiling on the number of concurrent goRoutines of a particular routine.
+// I cannot think of how to get the sync.WaitGroup to provide this feature. It is good for waiting on goRoutines to finish but
+// I don't know how to configure sync to set a ceiling on the number of concurrent goRoutines.
+
+// var eventCh chan struct{}{}
+
+//   main
+//   	eventCh=make(chan struct{}{},5)
+//   	for {
+//   		eventCh <- x  // the buffers will fill only if the receiptent of the message does not run a goroutine i.e. is synchronised. if the recipient is not a goroutine their will be only one process
+//                        // so to keep the main program from waiting for it to finish we include a buffer on the channel. Hopefully before the buffer fills the recipient will finish and
+//                        // execute again.
+//   	}                 // if the recipeient runs as go routine then the recipient will empty the buffer as fast as the main will fill it. This may lead to func X spawning a very large
+//                        //. number of goroutines the number of which are not impacted by the channel buffer size.
+//   }
+
+//   func_ X1
+//  	for e = range eventCh { // this will read from channel, start goRoutine and then read from channel again until it is closed
+//			go Routine          // The buffer will limit the number of active groutines. As one finishes this will free up a buffer slot and main will fill it with another request to be immediately read by X.
+//  	}
+//  }
+//   func_ X2
+//  	for e = range eventCh { // this will read from channel, start goRoutine and then read from channel again until it is closed
+//			Routine            // The buffer will limit the number of active groutines. As one finishes this will free up a buffer slot and main will fill it with another request to be immediately read by X.
+//  	}
+//  }
+//
+//   So channel buffers are not useful for recipients of channel events that execute go routines. They are useful when the recipient is synchronised with the execution.
+//    For goroutine recipients we need a mechanism that can throttle the running of goroutines. This package provides this service.
+//
+//   func_ Y
+//   	z := grmgr.New(<routine>, 5)
+//
+//   		for e = range eventCh
+//   			go Routine          // same as above, unlimited concurrent go routines run. go routine includes Start and End channel messages that increments & decrements internal counter.
+//				<-z.Wait()          //  grmgr will send event  on channel if there are less than Ceiling number of concurrent go routines.
+//   	}							// Note grmgr limit must be less than channel buffer. So set a large channel buffer and use grmgr to fluctuate between.
+//   }
+
+//   func_ Routine {
+
+//	}
+//
+// New registers a new routine and its ceiling (max concurrency) combination with the default grmgr service.
+func New(r string, c Ceiling, min ...Ceiling) *Limiter {
+	return Default().New(r, c, min...)
+}
+
+// NewConfig configures a Limiter throttle with the default grmgr service. See Manager.NewConfig.
+func NewConfig(r string, c Ceiling, down int, up int, min Ceiling, h string) (*Limiter, error) {
+	return Default().NewConfig(r, c, down, up, min, h)
+}
+
+// New registers a new routine and its ceiling (max concurrency) combination.
+// An invalid configuration is logged and clamped, so a Limiter is always returned. A ceiling below 1
+// grants nothing until raised. Use NewLimiter to have it rejected instead.
+func (m *Manager) New(r string, c Ceiling, min ...Ceiling) *Limiter {
+
+	mc := 1 // minimum ceiling
+	if len(min) > 0 {
+		mc = min[0]
+	}
+	return m.newClamped(LimiterConfig{Name: r, Ceiling: c, Min: mc, Up: 1, Down: 2, Hold: 30 * time.Second})
+}
+
+//limitUnmarshaler := grmgr.NewConfig("unmarshaler", *concurrent*2, 2,1,3,"1m")
+
+// NewConfig - configure a Limiter throttle
+// r: limiter name
+// c: ceiling value (also the maximum value for the throttle)
+// down: adjust current ceiling down by specified value
+// up:   adjust current ceiling up by specified value
+// min: minimum value of ceiling
+// h: hold any change for this duration (in a string value that can be converted to time.Duration) e.g. "5s" for five seconds
+// An invalid configuration returns a *ConfigError. See also NewLimiter.
+func (m *Manager) NewConfig(r string, c Ceiling, down int, up int, min Ceiling, h string) (*Limiter, error) {
+
+	hold, err := time.ParseDuration(h)
+	if err != nil {
+		err = &ConfigError{Limiter: r, Field: "hold", Err: fmt.Errorf("%w: %s", ErrHold, err)}
+		logErr(err)
+		return nil, err
+	}
+	return m.NewLimiter(LimiterConfig{Name: r, Ceiling: c, Min: min, Up: up, Down: down, Hold: hold})
+}
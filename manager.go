@@ -0,0 +1,705 @@
+package grmgr
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+type throttle_ byte
+
+func (t throttle_) Up() error {
+	return Default().Up()
+}
+
+func (t throttle_) Down() error {
+	return Default().Down()
+}
+
+// Stop halts admissions to all Limiters of the default grmgr service. See Manager.Stop.
+func (t throttle_) Stop() {
+	if err := Default().Stop(); err != nil {
+		logErr(err)
+	}
+}
+
+// String returns a table of all Limiters of the default grmgr service with their dop (ceiling),
+// units in use and routines waiting, or a "not running" line when grmgr is not running.
+func (t throttle_) String() string {
+	m := Default()
+	select {
+	case <-m.powerOffCh:
+		return "grmgr: not running\n"
+	default:
+	}
+	if !m.ran.Load() {
+		return "grmgr: not running\n"
+	}
+	return statusTable(m.Status())
+}
+
+// Status returns the state of all Limiters of the default grmgr service.
+func (t throttle_) Status() []LimiterStatus {
+	return Default().Status()
+}
+
+// Control throttles all Limiters of the default grmgr service
+var Control throttle_
+
+// use channels to synchronise access to shared memory ie. the various maps, rLimiterMap.rCntMap.
+// "don't communicate by sharing memory, share memory by communicating"
+// grmgr runs as a single goroutine with sole access to the shared memory objects. Clients request or update data via channel requests.
+// TODO: keep adding entries to map. Determine when to purge entry from maps.
+type Config map[string]interface{}
+
+// Manager is a grmgr service. Each Manager runs as a single goroutine (see Run) with sole access to the
+// state of its Limiters. The package level functions, New, NewConfig, PowerOn and Control,
+// operate on a default Manager.
+type Manager struct {
+	// Channels
+	endCh          chan Routine
+	throttleDownCh chan throttleReq
+	throttleUpCh   chan throttleReq
+	//
+	rAskCh      chan Routine
+	rWaitCh     chan *waiter
+	rEndNCh     chan weightedEnd
+	rTryAskCh   chan tryAsk
+	rWithdrawCh chan *waiter
+	// fast path
+	fastAskCh      chan *waiter
+	fastWakeCh     chan Routine
+	fastWithdrawCh chan *waiter
+	//
+	registerCh   chan *Limiter
+	unRegisterCh chan Routine
+	getCh        chan getReq
+	statusCh     chan chan []LimiterStatus
+	metricsCh    chan chan []LimiterMetrics
+	stopCh       chan chan struct{}
+	historyCh    chan historyReq
+	//
+	setCh         chan setReq
+	scaleCh       chan scaleReq
+	scaleInterval time.Duration
+	// scaling profiles
+	profileCh chan profileReq
+	profiles  map[string]*Profile
+	profTimer *time.Timer
+	// config file hot reload
+	reload reloader
+	// WaitTracer, see SetWaitTracer
+	tracer atomic.Value
+	// graceful shutdown, see drain
+	drainTimeout time.Duration
+	draining     bool
+	drainCh      chan struct{} // closed when draining starts
+	// closed when Run returns
+	powerOffCh chan struct{}
+	ran        atomic.Bool // Run has been called
+	//
+	rLimit rLimiterMap
+	seq    uint64 // waiter arrival sequence
+	stats  stats
+}
+
+// defaultMgr is replaced each time PowerOn returns, so PowerOn can be run again. See Default.
+var defaultMgr atomic.Pointer[Manager]
+
+// EndCh is the default grmgr service end channel, shared by each run of PowerOn. Prefer Limiter.Done().
+var EndCh chan Routine
+
+func init() {
+	m := NewManager()
+	EndCh = m.endCh
+	defaultMgr.Store(m)
+}
+
+// Default returns the default grmgr service, run by PowerOn and used by the package level
+// functions, e.g. to pass it to code written against a *Manager. Once PowerOn returns the
+// default is a new Manager, to be run by the next PowerOn, so Default should be called again.
+func Default() *Manager {
+	return defaultMgr.Load()
+}
+
+// renewDefault replaces m, the default Manager once it has run, with a new Manager.
+func renewDefault(m *Manager) {
+	fresh := NewManager()
+	fresh.endCh = EndCh
+	defaultMgr.CompareAndSwap(m, fresh)
+}
+
+// NewManager returns a grmgr service which must be started using Run. Limiters are registered
+// with it using its New and NewConfig methods.
+func NewManager(cfg ...Config) *Manager {
+
+	m := &Manager{
+		endCh:          make(chan Routine),
+		throttleDownCh: make(chan throttleReq),
+		throttleUpCh:   make(chan throttleReq),
+		rAskCh:         make(chan Routine),
+		rWaitCh:        make(chan *waiter),
+		rEndNCh:        make(chan weightedEnd),
+		rTryAskCh:      make(chan tryAsk),
+		rWithdrawCh:    make(chan *waiter),
+		fastAskCh:      make(chan *waiter),
+		fastWakeCh:     make(chan Routine),
+		fastWithdrawCh: make(chan *waiter),
+		registerCh:     make(chan *Limiter),
+		unRegisterCh:   make(chan Routine),
+		getCh:          make(chan getReq),
+		statusCh:       make(chan chan []LimiterStatus),
+		metricsCh:      make(chan chan []LimiterMetrics),
+		stopCh:         make(chan chan struct{}),
+		historyCh:      make(chan historyReq),
+		setCh:          make(chan setReq),
+		scaleCh:        make(chan scaleReq),
+		scaleInterval:  time.Second,
+		profileCh:      make(chan profileReq),
+		profiles:       make(map[string]*Profile),
+		reload:         reloader{interval: 5 * time.Second},
+		drainCh:        make(chan struct{}),
+		powerOffCh:     make(chan struct{}),
+		rLimit:         make(rLimiterMap),
+	}
+	m.configure(cfg...)
+
+	return m
+}
+
+func (m *Manager) configure(cfg ...Config) {
+
+	if len(cfg) == 0 {
+		return
+	}
+	for k, v := range cfg[0] {
+		switch k := strings.ToLower(k); k {
+		case "scaleinterval":
+			d, err := toDuration(v)
+			if err != nil || d <= 0 {
+				logErr(fmt.Errorf("scaleinterval should be a positive time.Duration or duration string: %v", v))
+				continue
+			}
+			m.scaleInterval = d
+		case "draintimeout":
+			d, err := toDuration(v)
+			if err != nil || d < 0 {
+				logErr(fmt.Errorf("draintimeout should be a time.Duration or duration string: %v", v))
+				continue
+			}
+			m.drainTimeout = d
+		default:
+			if !m.reload.configure(k, v) && !m.stats.configure(k, v) {
+				logErr(fmt.Errorf("not a supported config key  %q", k))
+			}
+		}
+	}
+	m.stats.validate()
+}
+
+// Up throttles up all Limiters registered with m.
+func (m *Manager) Up() error {
+	return m.throttleAll(m.throttleUpCh)
+}
+
+// Down throttles down all Limiters registered with m.
+func (m *Manager) Down() error {
+	return m.throttleAll(m.throttleDownCh)
+}
+
+func (m *Manager) throttleAll(ch chan throttleReq) error {
+	select {
+	case ch <- throttleReq{r: Routine("__all")}:
+	case <-m.powerOffCh:
+		return ErrPoweredOff
+	}
+	return nil
+}
+
+// throttleReq throttles Limiter r, or all top level Limiters ("__all"), up or down by their step.
+type throttleReq struct {
+	r Routine
+	// if not nil, receives the Limiters the request was applied to, i.e. not rejected by their
+	// hold period. A Down is applied only if it lowered the ceiling.
+	applied chan []Routine
+}
+
+func (t throttleReq) reply(applied []Routine) {
+	if t.applied != nil {
+		t.applied <- applied
+	}
+}
+
+// PowerOn runs the default grmgr service until ctx is cancelled. PowerOn may be run again once it
+// has returned, with the Limiters of the earlier run being powered off.
+func PowerOn(ctx context.Context, wpStart *sync.WaitGroup, wgEnd *sync.WaitGroup, cfg ...Config) {
+
+	defer wgEnd.Done()
+
+	m := Default()
+	if m.ran.Load() {
+		// run directly by Manager.Run
+		renewDefault(m)
+		m = Default()
+	}
+	defer renewDefault(m)
+	m.configure(cfg...)
+	m.run(ctx, wpStart.Done)
+}
+
+// Run runs the grmgr service until ctx is cancelled. A Manager can only be run once; a repeated
+// Run is logged and returns immediately.
+func (m *Manager) Run(ctx context.Context) {
+	m.run(ctx, func() {})
+}
+
+func (m *Manager) run(ctx context.Context, started func()) {
+
+	if !m.ran.CompareAndSwap(false, true) {
+		logErr(fmt.Errorf("grmgr: a Manager can only be run once"))
+		started()
+		return
+	}
+
+	var (
+		r Routine
+		l *Limiter
+		// autoscaling interrupt, started by first AutoScale request
+		scaleTick *time.Ticker
+		scaleC    <-chan time.Time
+		// graceful shutdown
+		done      = ctx.Done()
+		drainTick *time.Ticker
+		drainC    <-chan time.Time
+		deadline  <-chan time.Time
+	)
+	defer func() {
+		if scaleTick != nil {
+			scaleTick.Stop()
+		}
+		if drainTick != nil {
+			drainTick.Stop()
+		}
+	}()
+
+	m.stats.start()
+	if len(m.reload.path) > 0 {
+		m.reloadConfig()
+		m.reload.start()
+	}
+	logAlert("Started.")
+	started()
+
+	for {
+
+		select {
+
+		case l = <-m.registerCh:
+
+			l.regErr = m.register(l)
+			// release NewConfig
+			l.ch <- struct{}{}
+
+		case r = <-m.endCh:
+
+			if l, ok := m.rLimit[r]; ok {
+				l.wg.Done()
+				l.add(-1)
+				l.complete()
+				l.release()
+
+			} else {
+				panic(fmt.Errorf("expected limiter %s in m.rLimit got nil", r))
+			}
+
+		case e := <-m.rEndNCh:
+
+			if l, ok := m.rLimit[e.r]; ok {
+				l.wg.Done()
+				l.add(-e.n)
+				l.complete()
+				l.release()
+
+			} else {
+				panic(fmt.Errorf("expected limiter %s in m.rLimit got nil", e.r))
+			}
+
+		case r = <-m.rAskCh:
+
+			// Ask() - respond on the limiter's own channel
+			if l, ok := m.rLimit[r]; ok {
+				l.ask(&waiter{r: r, n: 1, ch: l.ch})
+			} else {
+				panic(fmt.Errorf("expected limiter %s in rLimit got nil", r))
+			}
+
+		case w := <-m.rWaitCh:
+
+			if l, ok := m.rLimit[w.r]; ok {
+				l.ask(w)
+			} else {
+				panic(fmt.Errorf("expected limiter %s in rLimit got nil", w.r))
+			}
+
+		case t := <-m.rTryAskCh:
+
+			if l, ok := m.rLimit[t.r]; ok {
+				// capacity may be held back for a queued (weighted) waiter
+				if len(l.waitq) == 0 && l.fits(1) && !m.draining {
+					l.wg.Add(1)
+					l.add(1)
+					t.resp <- true
+				} else {
+					t.resp <- false
+				}
+			} else {
+				panic(fmt.Errorf("expected limiter %s in m.rLimit got nil", t.r))
+			}
+
+		case w := <-m.rWithdrawCh:
+
+			if l, ok := m.rLimit[w.r]; ok {
+				// caller gave up waiting
+				l.withdraw(w)
+			} else {
+				panic(fmt.Errorf("expected limiter %s in rLimit got nil", w.r))
+			}
+
+		case a := <-m.fastAskCh:
+
+			if l, ok := m.rLimit[a.r]; ok {
+				if m.draining {
+					a.rejected = true
+					l.fwait.Add(-1)
+					continue
+				}
+				m.seq++
+				a.seq = m.seq
+				l.fq.push(a)
+				l.fastRelease()
+			} else {
+				panic(fmt.Errorf("expected limiter %s in rLimit got nil", a.r))
+			}
+
+		case r = <-m.fastWakeCh:
+
+			if l, ok := m.rLimit[r]; ok {
+				l.fwake.Store(0)
+				l.fastRelease()
+			} else {
+				panic(fmt.Errorf("expected limiter %s in rLimit got nil", r))
+			}
+
+		case a := <-m.fastWithdrawCh:
+
+			if l, ok := m.rLimit[a.r]; ok {
+				l.fastWithdraw(a)
+			} else {
+				panic(fmt.Errorf("expected limiter %s in rLimit got nil", a.r))
+			}
+
+		case req := <-m.throttleDownCh:
+
+			r = req.r
+			allr := make(rLimiterMap)
+			if r == Routine("__all") {
+				allr = m.rLimit
+			} else if l, ok := m.rLimit[r]; ok {
+				allr[r] = l
+			}
+			t0 := time.Now()
+			var applied []Routine
+
+			for _, v := range allr {
+				//
+				if r == Routine("__all") && v.parent != nil {
+					// follows its parent
+					continue
+				}
+
+				if t0.Sub(v.throttleDownActioned) < v.hold {
+					logAlert("throttleDown: to soon to throttle down after last throttled action")
+					v.metrics.holds.Add(1)
+				} else {
+
+					if t0.Sub(v.throttleUpActioned) < v.hold {
+						logAlert("throttleDown: to soon to throttle down after last throttled action")
+						v.metrics.holds.Add(1)
+					} else {
+
+						// throttle down by 20%. Once changed cannot be modified for 2 minutes.
+
+						c0 := v.c
+						v.c -= v.down
+						v.throttleDownActioned = t0
+
+						if v.c < v.minc {
+							v.c = v.minc
+							logAlert(fmt.Sprintf("throttleDown: Throttling has reached minimum allowed [%d], for %s", v.minc, v.or))
+						} else {
+							logAlert(fmt.Sprintf("throttleDown: %s throttled down to %d [minimum: %d]", v.or, v.c, v.minc))
+						}
+						v.metrics.changed(c0, v.c)
+						v.publish()
+						v.scaleChildren()
+						if v.c < c0 {
+							applied = append(applied, v.r)
+						}
+					}
+				}
+			}
+			req.reply(applied)
+
+		case req := <-m.throttleUpCh:
+
+			r = req.r
+			allr := make(rLimiterMap)
+			if r == Routine("__all") {
+				allr = m.rLimit
+			} else if l, ok := m.rLimit[r]; ok {
+				allr[r] = l
+			}
+			t0 := time.Now()
+			var applied []Routine
+
+			for _, v := range allr {
+				//
+				if r == Routine("__all") && v.parent != nil {
+					// follows its parent
+					continue
+				}
+
+				if t0.Sub(v.throttleDownActioned) < v.hold {
+					logAlert("throttleUp: to soon to throttle up after last throttled action")
+					v.metrics.holds.Add(1)
+				} else {
+
+					if t0.Sub(v.throttleUpActioned) < v.hold {
+						logAlert("throttleUp: to soon to throttle up after last throttled action")
+						v.metrics.holds.Add(1)
+					} else {
+
+						// throttle down by 20%. Once changed cannot be modified for 2 minutes.
+
+						c0 := v.c
+						v.c += v.up
+						v.throttleUpActioned = t0
+
+						if v.c > v.maxc {
+							v.c = v.maxc
+							logAlert(fmt.Sprintf("throttleUp: Throttling has reached maximum allowed [%d], for %s", v.maxc, v.or))
+						} else {
+							logAlert(fmt.Sprintf("throttleUp: %s throttled up to %d [minimum: %d]", v.or, v.c, v.minc))
+						}
+						v.metrics.changed(c0, v.c)
+						v.publish()
+						v.scaleChildren()
+						v.release()
+						// a Limiter at its maximum has nothing left to restore
+						applied = append(applied, v.r)
+					}
+				}
+			}
+			req.reply(applied)
+
+		case req := <-m.setCh:
+
+			if req.r == Routine("__all") {
+				var err error
+				for _, l := range m.rLimit {
+					if e := l.apply(req, time.Now()); e != nil {
+						err = e
+					}
+				}
+				req.err <- err
+			} else if l, ok := m.rLimit[req.r]; ok {
+				req.err <- l.apply(req, time.Now())
+			} else {
+				// unregistered since
+				req.err <- &ControlError{Limiter: req.r, Err: ErrNotRegistered}
+			}
+
+		case s := <-m.scaleCh:
+
+			if l, ok := m.rLimit[s.r]; ok {
+				l.scaler = s.s
+				// start observation afresh
+				l.add(0)
+				l.completed, l.area = 0, 0
+				l.fdone.Store(0)
+				if s.s != nil && scaleTick == nil {
+					scaleTick = time.NewTicker(m.scaleInterval)
+					scaleC = scaleTick.C
+				}
+				logAlert(fmt.Sprintf("AutoScale %s: %t", s.r, s.s != nil))
+				s.err <- nil
+			} else {
+				s.err <- &ControlError{Limiter: s.r, Err: ErrNotRegistered}
+			}
+
+		case t := <-scaleC:
+
+			m.autoscale(t)
+
+		case req := <-m.profileCh:
+
+			req.err <- m.doProfile(req, time.Now())
+
+		case t := <-m.profileC():
+
+			m.runProfiles(t)
+
+		case t := <-m.stats.snapC():
+
+			m.stats.snap(t, m.rLimit)
+
+		case <-m.reload.tickC():
+
+			if m.reload.changed() {
+				m.reloadConfig()
+			}
+
+		case <-m.reload.hupC():
+
+			logAlert("SIGHUP received")
+			m.reloadConfig()
+
+		case req := <-m.getCh:
+
+			req.resp <- m.rLimit[req.r]
+
+		case resp := <-m.statusCh:
+
+			resp <- m.status()
+
+		case resp := <-m.metricsCh:
+
+			resp <- m.metrics()
+
+		case ack := <-m.stopCh:
+
+			if !m.draining {
+				logAlert("Stop: halting admissions to all limiters")
+				m.drain()
+			}
+			close(ack)
+
+		case req := <-m.historyCh:
+
+			req.resp <- m.stats.history[string(req.r)].last(m.stats.capacity)
+
+		case r = <-m.unRegisterCh:
+
+			if l, ok := m.rLimit[r]; ok {
+				l.detach()
+			}
+			delete(m.rLimit, r)
+			m.stats.unregister(r)
+			logAlert(fmt.Sprintf("Unregister %s", r))
+
+		case <-done:
+
+			done = nil
+			if m.drainTimeout == 0 {
+				m.powerOff()
+				return
+			}
+			logAlert(fmt.Sprintf("Draining in-flight tasks, waiting up to %s...", m.drainTimeout))
+			if !m.draining {
+				m.drain()
+			}
+			if m.idle() {
+				m.powerOff()
+				return
+			}
+			drainTick = time.NewTicker(10 * time.Millisecond)
+			drainC = drainTick.C
+			deadline = time.After(m.drainTimeout)
+
+		case <-drainC:
+
+			if m.idle() {
+				logAlert("Drained.")
+				m.powerOff()
+				return
+			}
+
+		case <-deadline:
+
+			for _, s := range m.stragglers() {
+				logAlert(fmt.Sprintf("Drain timeout: limiter %s still running tasks", s))
+			}
+			m.powerOff()
+			return
+		}
+	}
+}
+
+// powerOff stops grmgr's services and releases routines blocked on grmgr.
+// Called by grmgr only.
+func (m *Manager) powerOff() {
+
+	close(m.powerOffCh)
+	if m.profTimer != nil {
+		m.profTimer.Stop()
+	}
+	m.reload.stop()
+	m.stats.stop()
+	logAlert(fmt.Sprintf("Number of map entries not deleted: %d ", len(m.rLimit)))
+	for k := range m.rLimit {
+		logAlert(fmt.Sprintf("rLimit Map Entry: %s", k))
+	}
+	logAlert("Shutdown.")
+}
+
+// register adds l to rLimit under a unique routine name, beneath its parent if it has one.
+// Called by grmgr only.
+func (m *Manager) register(l *Limiter) error {
+
+	var p *Limiter
+	if len(l.pname) > 0 {
+		var ok bool
+		if p, ok = m.rLimit[l.pname]; !ok {
+			return &ConfigError{Limiter: l.or, Field: "parent", Err: fmt.Errorf("%w: %q is not registered", ErrParent, l.pname)}
+		}
+		if p.fast {
+			return &ConfigError{Limiter: l.or, Field: "parent", Err: fmt.Errorf("%w: fast Limiter %q cannot be a parent", ErrParent, l.pname)}
+		}
+	}
+
+	// check not already registered -
+	// generate unique label
+	var e byte = 65
+	for {
+		if _, ok := m.rLimit[l.r]; !ok {
+			// unique label
+			break
+		}
+		// routine r already exists, generate a unique value
+		l.r += string(e)
+		e++
+	}
+	m.rLimit[l.r] = l
+	if p != nil {
+		l.attach(p)
+	}
+	if m.draining {
+		// admissions have stopped
+		l.publish()
+	}
+	return nil
+}
+
+// toDuration accepts a time.Duration or a string that can be converted to a time.Duration e.g. "5s"
+func toDuration(v interface{}) (time.Duration, error) {
+	switch d := v.(type) {
+	case time.Duration:
+		return d, nil
+	case string:
+		return time.ParseDuration(d)
+	}
+	return 0, fmt.Errorf("not a duration: %T", v)
+}
@@ -0,0 +1,114 @@
+package grmgr
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// startManager runs a new Manager for the duration of the test. The returned cancel func powers
+// it off sooner, after which m.powerOffCh is closed.
+func startManager(t *testing.T, cfg ...Config) (*Manager, context.CancelFunc) {
+	t.Helper()
+
+	m := NewManager(cfg...)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		m.Run(ctx)
+		close(done)
+	}()
+	t.Cleanup(func() {
+		cancel()
+		<-done
+	})
+	return m, cancel
+}
+
+func TestManagerCeiling(t *testing.T) {
+	m, _ := startManager(t)
+	l := m.New("ceiling", 3)
+
+	if peak := runTasks(l, 50, func(int) int { return 1 }); peak > 3 {
+		t.Errorf("expected at most 3 concurrent tasks got %d", peak)
+	}
+}
+
+func TestManagersAreIndependent(t *testing.T) {
+	m1, _ := startManager(t)
+	m2, _ := startManager(t)
+
+	l1 := m1.New("same", 1)
+	l2 := m2.New("same", 1)
+	if l1.Routine() != l2.Routine() {
+		t.Errorf("expected same routine name in each manager, got %q and %q", l1.Routine(), l2.Routine())
+	}
+
+	// saturating one manager's limiter must not affect the other
+	l1.Control()
+	if !l2.TryControl() {
+		t.Fatal("expected slot from second manager")
+	}
+	l2.Done()
+	l1.Done()
+}
+
+func TestStringNotRunning(t *testing.T) {
+	str := func() string {
+		ch := make(chan string, 1)
+		go func() { ch <- Control.String() }()
+		select {
+		case s := <-ch:
+			return s
+		case <-time.After(time.Second):
+			t.Fatal("String() blocked")
+			return ""
+		}
+	}
+	if s := str(); !strings.Contains(s, "not running") {
+		t.Errorf("expected not running before PowerOn got %q", s)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	var wpStart, wpEnd sync.WaitGroup
+	wpStart.Add(1)
+	wpEnd.Add(1)
+	go PowerOn(ctx, &wpStart, &wpEnd)
+	wpStart.Wait()
+
+	New("listed", 1)
+	if s := str(); !strings.Contains(s, "listed") {
+		t.Errorf("expected Limiter in table got %q", s)
+	}
+	cancel()
+	wpEnd.Wait()
+	if s := str(); !strings.Contains(s, "not running") {
+		t.Errorf("expected not running after power off got %q", s)
+	}
+}
+
+func TestPowerOnAgain(t *testing.T) {
+	for run := 0; run < 2; run++ {
+		ctx, cancel := context.WithCancel(context.Background())
+		var wpStart, wpEnd sync.WaitGroup
+		wpStart.Add(1)
+		wpEnd.Add(1)
+		go PowerOn(ctx, &wpStart, &wpEnd)
+		wpStart.Wait()
+
+		l := New("again", 1)
+		if !l.TryControl() {
+			t.Fatalf("run %d: expected slot from a registered Limiter", run)
+		}
+		l.Done()
+		cancel()
+		wpEnd.Wait()
+	}
+
+	// a Manager is run once only
+	m, cancel := startManager(t)
+	cancel()
+	<-m.powerOffCh
+	m.Run(context.Background())
+}
@@ -0,0 +1,181 @@
+package grmgr
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// waitBuckets are the upper bounds, in seconds, of the Control() wait time histogram.
+var waitBuckets = [...]float64{0.0001, 0.0005, 0.001, 0.005, 0.01, 0.05, 0.1, 0.5, 1, 5, 10, 30, 60}
+
+// counters are a Limiter's cumulative metrics. They are updated on both client goroutines and grmgr.
+type counters struct {
+	asks        atomic.Int64 // Control(), TryControl() and Ask() requests
+	completions atomic.Int64
+	ups         atomic.Int64 // ceiling increases
+	downs       atomic.Int64 // ceiling decreases
+	holds       atomic.Int64 // Up(), Down() or autoscale changes rejected by the hold period
+	//
+	wait    [len(waitBuckets) + 1]atomic.Int64 // non-cumulative bucket counts, last is +Inf
+	waitSum atomic.Int64                       // nanoseconds
+}
+
+// observeWait records the time a routine waited in Control() for a slot.
+func (c *counters) observeWait(d time.Duration) {
+	s := d.Seconds()
+	i := 0
+	for i < len(waitBuckets) && s > waitBuckets[i] {
+		i++
+	}
+	c.wait[i].Add(1)
+	c.waitSum.Add(int64(d))
+}
+
+// waitCount returns the number of waits observed.
+func (c *counters) waitCount() int64 {
+	var n int64
+	for i := range c.wait {
+		n += c.wait[i].Load()
+	}
+	return n
+}
+
+// changed counts a ceiling change.
+func (c *counters) changed(from, to Ceiling) {
+	if to > from {
+		c.ups.Add(1)
+	} else if to < from {
+		c.downs.Add(1)
+	}
+}
+
+// LimiterMetrics is a Limiter's status and cumulative counters at a point in time.
+type LimiterMetrics struct {
+	LimiterStatus
+	Asks          int64 // Control(), TryControl() and Ask() requests
+	Completions   int64
+	ThrottleUps   int64 // ceiling increases
+	ThrottleDowns int64 // ceiling decreases
+	HoldsRejected int64 // ceiling changes rejected during the hold period
+	//
+	wait    [len(waitBuckets) + 1]int64
+	waitSum time.Duration
+}
+
+// Metrics returns the metrics of the default grmgr service. See Manager.Metrics.
+func Metrics() []LimiterMetrics {
+	return Default().Metrics()
+}
+
+// Metrics returns the status and counters of every Limiter registered with m, ordered by name.
+// Returns nil once m is powered off.
+func (m *Manager) Metrics() []LimiterMetrics {
+	resp := make(chan []LimiterMetrics, 1)
+	select {
+	case m.metricsCh <- resp:
+	case <-m.powerOffCh:
+		return nil
+	}
+	return <-resp
+}
+
+// metrics returns the metrics of all limiters.
+// Called by grmgr only.
+func (m *Manager) metrics() []LimiterMetrics {
+
+	st := m.status()
+	lm := make([]LimiterMetrics, len(st))
+	for i, s := range st {
+		c := &m.rLimit[s.Name].metrics
+		lm[i] = LimiterMetrics{
+			LimiterStatus: s,
+			Asks:          c.asks.Load(),
+			Completions:   c.completions.Load(),
+			ThrottleUps:   c.ups.Load(),
+			ThrottleDowns: c.downs.Load(),
+			HoldsRejected: c.holds.Load(),
+			waitSum:       time.Duration(c.waitSum.Load()),
+		}
+		for j := range c.wait {
+			lm[i].wait[j] = c.wait[j].Load()
+		}
+	}
+	return lm
+}
+
+// WriteMetrics writes the metrics of the default grmgr service. See Manager.WriteMetrics.
+func WriteMetrics(w io.Writer) error {
+	return Default().WriteMetrics(w)
+}
+
+// MetricsHandler returns the metrics handler of the default grmgr service. See Manager.MetricsHandler.
+func MetricsHandler() http.Handler {
+	return Default().MetricsHandler()
+}
+
+// MetricsHandler returns an http.Handler serving the metrics of m in the Prometheus text format,
+// e.g. mux.Handle("/metrics", m.MetricsHandler())
+func (m *Manager) MetricsHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		if err := m.WriteMetrics(w); err != nil {
+			logErr(fmt.Errorf("metrics: %w", err))
+		}
+	})
+}
+
+// WriteMetrics writes per-Limiter gauges, counters and a Control() wait time histogram in the
+// Prometheus text exposition format. Every metric has a "limiter" label.
+func (m *Manager) WriteMetrics(w io.Writer) error {
+
+	lm := m.Metrics()
+	if lm == nil {
+		return ErrPoweredOff
+	}
+
+	bw := bufio.NewWriter(w)
+
+	metric := func(name, typ, help string, v func(l *LimiterMetrics) int64) {
+		fmt.Fprintf(bw, "# HELP grmgr_%s %s\n# TYPE grmgr_%s %s\n", name, help, name, typ)
+		for i := range lm {
+			fmt.Fprintf(bw, "grmgr_%s{limiter=\"%s\"} %d\n", name, escapeLabel(lm[i].Name), v(&lm[i]))
+		}
+	}
+	metric("active", "gauge", "Units in use.", func(l *LimiterMetrics) int64 { return int64(l.Active) })
+	metric("waiting", "gauge", "Routines waiting for a slot.", func(l *LimiterMetrics) int64 { return int64(l.Waiting) })
+	metric("ceiling", "gauge", "Current ceiling (dop).", func(l *LimiterMetrics) int64 { return int64(l.Ceiling) })
+	metric("ceiling_max", "gauge", "Maximum ceiling.", func(l *LimiterMetrics) int64 { return int64(l.Max) })
+	metric("ceiling_min", "gauge", "Minimum ceiling.", func(l *LimiterMetrics) int64 { return int64(l.Min) })
+	metric("asks_total", "counter", "Requests for a slot.", func(l *LimiterMetrics) int64 { return l.Asks })
+	metric("completions_total", "counter", "Tasks completed.", func(l *LimiterMetrics) int64 { return l.Completions })
+	metric("throttle_up_total", "counter", "Increases in ceiling.", func(l *LimiterMetrics) int64 { return l.ThrottleUps })
+	metric("throttle_down_total", "counter", "Decreases in ceiling.", func(l *LimiterMetrics) int64 { return l.ThrottleDowns })
+	metric("holds_rejected_total", "counter", "Ceiling changes rejected during the hold period.", func(l *LimiterMetrics) int64 { return l.HoldsRejected })
+
+	fmt.Fprintf(bw, "# HELP grmgr_wait_seconds Time spent waiting in Control() for a slot.\n# TYPE grmgr_wait_seconds histogram\n")
+	for i := range lm {
+		name := escapeLabel(lm[i].Name)
+		var cum int64
+		for j, le := range waitBuckets {
+			cum += lm[i].wait[j]
+			fmt.Fprintf(bw, "grmgr_wait_seconds_bucket{limiter=\"%s\",le=\"%s\"} %d\n", name, strconv.FormatFloat(le, 'g', -1, 64), cum)
+		}
+		cum += lm[i].wait[len(waitBuckets)]
+		fmt.Fprintf(bw, "grmgr_wait_seconds_bucket{limiter=\"%s\",le=\"+Inf\"} %d\n", name, cum)
+		fmt.Fprintf(bw, "grmgr_wait_seconds_sum{limiter=\"%s\"} %s\n", name, strconv.FormatFloat(lm[i].waitSum.Seconds(), 'g', -1, 64))
+		fmt.Fprintf(bw, "grmgr_wait_seconds_count{limiter=\"%s\"} %d\n", name, cum)
+	}
+	return bw.Flush()
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func escapeLabel(r Routine) string {
+	return labelEscaper.Replace(string(r))
+}
@@ -0,0 +1,61 @@
+package grmgr
+
+import (
+	"io"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMetricsHandler(t *testing.T) {
+	m, _ := startManager(t)
+	l, _ := m.NewConfig("loader", 2, 1, 1, 1, "1h")
+	f := m.NewFast("fast", 2)
+
+	for _, lim := range []*Limiter{l, f} {
+		lim.Control()
+		lim.Control()
+		go func(lim *Limiter) {
+			time.Sleep(20 * time.Millisecond)
+			lim.Done()
+		}(lim)
+		lim.Control() // waits for a slot
+		lim.Done()
+		lim.Done()
+		lim.Wait()
+	}
+	l.Down() // rejected by hold
+	l.SetCeiling(1)
+
+	rec := httptest.NewRecorder()
+	m.MetricsHandler().ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	body, _ := io.ReadAll(rec.Body)
+	out := string(body)
+
+	for _, want := range []string{
+		"# TYPE grmgr_active gauge",
+		`grmgr_ceiling{limiter="loader"} 1`,
+		`grmgr_ceiling_max{limiter="fast"} 2`,
+		`grmgr_asks_total{limiter="loader"} 3`,
+		`grmgr_asks_total{limiter="fast"} 3`,
+		`grmgr_completions_total{limiter="loader"} 3`,
+		`grmgr_completions_total{limiter="fast"} 3`,
+		`grmgr_throttle_down_total{limiter="loader"} 1`,
+		`grmgr_holds_rejected_total{limiter="loader"} 1`,
+		"# TYPE grmgr_wait_seconds histogram",
+		`grmgr_wait_seconds_bucket{limiter="loader",le="0.005"} 2`,
+		`grmgr_wait_seconds_bucket{limiter="fast",le="+Inf"} 3`,
+		`grmgr_wait_seconds_count{limiter="loader"} 3`,
+	} {
+		if !strings.Contains(out, want+"\n") {
+			t.Errorf("expected %q in\n%s", want, out)
+		}
+	}
+}
+
+func TestEscapeLabel(t *testing.T) {
+	if s := escapeLabel(Routine("a\"b\\c\n")); s != `a\"b\\c\n` {
+		t.Errorf("unexpected escaped label %s", s)
+	}
+}
@@ -0,0 +1,22 @@
+module github.com/ros2hp/grmgr/otelgrmgr
+
+go 1.19
+
+require (
+	github.com/ros2hp/grmgr v0.0.0-20261016224940-9950c2d01414
+	go.opentelemetry.io/otel v1.16.0
+	go.opentelemetry.io/otel/metric v1.16.0
+	go.opentelemetry.io/otel/sdk v1.16.0
+	go.opentelemetry.io/otel/sdk/metric v0.39.0
+	go.opentelemetry.io/otel/trace v1.16.0
+)
+
+require (
+	github.com/BurntSushi/toml v1.3.2 // indirect
+	github.com/go-logr/logr v1.2.4 // indirect
+	github.com/go-logr/stdr v1.2.2 // indirect
+	github.com/ros2hp/method-db v0.0.0-20230209085444-fb7287db96d6 // indirect
+	github.com/satori/go.uuid v1.2.0 // indirect
+	golang.org/x/sys v0.8.0 // indirect
+	gopkg.in/yaml.v3 v3.0.1 // indirect
+)
@@ -0,0 +1,142 @@
+// Package otelgrmgr exports grmgr Limiter metrics to OpenTelemetry and records a trace span for
+// the time each routine spends waiting in Control() for a slot. It is a separate module so the
+// grmgr package itself does not depend on OpenTelemetry.
+//
+//	stop, err := otelgrmgr.Instrument(grmgr.Default())
+//	...
+//	defer stop()
+package otelgrmgr
+
+import (
+	"context"
+	"time"
+
+	"github.com/ros2hp/grmgr"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const instrumentationName = "github.com/ros2hp/grmgr/otelgrmgr"
+
+// LimiterKey is the attribute identifying the Limiter of a metric or span.
+const LimiterKey = attribute.Key("grmgr.limiter")
+
+type config struct {
+	mp metric.MeterProvider
+	tp trace.TracerProvider
+}
+
+// Option configures Instrument.
+type Option func(*config)
+
+// WithMeterProvider sets the MeterProvider, otherwise the global MeterProvider is used.
+func WithMeterProvider(mp metric.MeterProvider) Option {
+	return func(c *config) { c.mp = mp }
+}
+
+// WithTracerProvider sets the TracerProvider, otherwise the global TracerProvider is used.
+func WithTracerProvider(tp trace.TracerProvider) Option {
+	return func(c *config) { c.tp = tp }
+}
+
+// Instrument reports the Limiters of m to OpenTelemetry:
+//
+//	grmgr.active, grmgr.waiting                  gauges of units in use and routines waiting
+//	grmgr.ceiling, grmgr.ceiling.max/min         gauges of the current, maximum and minimum ceiling
+//	grmgr.asks, grmgr.completions                counters of requests for a slot and tasks completed
+//	grmgr.throttle.up/down, grmgr.holds.rejected counters of ceiling changes and changes rejected by hold
+//	grmgr.wait.duration                          histogram of time waiting for a slot, in seconds
+//
+// and records a "grmgr.wait" span, a child of the caller's context, for each wait in Control(),
+// ControlContext() etc. The returned stop function removes the instrumentation.
+func Instrument(m *grmgr.Manager, opts ...Option) (stop func() error, err error) {
+
+	c := config{mp: otel.GetMeterProvider(), tp: otel.GetTracerProvider()}
+	for _, o := range opts {
+		o(&c)
+	}
+	meter := c.mp.Meter(instrumentationName)
+
+	type observed struct {
+		name, desc string
+		counter    bool
+		v          func(l *grmgr.LimiterMetrics) int64
+		inst       metric.Int64Observable
+	}
+	obs := []observed{
+		{name: "grmgr.active", desc: "Units in use.", v: func(l *grmgr.LimiterMetrics) int64 { return int64(l.Active) }},
+		{name: "grmgr.waiting", desc: "Routines waiting for a slot.", v: func(l *grmgr.LimiterMetrics) int64 { return int64(l.Waiting) }},
+		{name: "grmgr.ceiling", desc: "Current ceiling (dop).", v: func(l *grmgr.LimiterMetrics) int64 { return int64(l.Ceiling) }},
+		{name: "grmgr.ceiling.max", desc: "Maximum ceiling.", v: func(l *grmgr.LimiterMetrics) int64 { return int64(l.Max) }},
+		{name: "grmgr.ceiling.min", desc: "Minimum ceiling.", v: func(l *grmgr.LimiterMetrics) int64 { return int64(l.Min) }},
+		{name: "grmgr.asks", desc: "Requests for a slot.", counter: true, v: func(l *grmgr.LimiterMetrics) int64 { return l.Asks }},
+		{name: "grmgr.completions", desc: "Tasks completed.", counter: true, v: func(l *grmgr.LimiterMetrics) int64 { return l.Completions }},
+		{name: "grmgr.throttle.up", desc: "Increases in ceiling.", counter: true, v: func(l *grmgr.LimiterMetrics) int64 { return l.ThrottleUps }},
+		{name: "grmgr.throttle.down", desc: "Decreases in ceiling.", counter: true, v: func(l *grmgr.LimiterMetrics) int64 { return l.ThrottleDowns }},
+		{name: "grmgr.holds.rejected", desc: "Ceiling changes rejected during the hold period.", counter: true, v: func(l *grmgr.LimiterMetrics) int64 { return l.HoldsRejected }},
+	}
+	insts := make([]metric.Observable, len(obs))
+	for i := range obs {
+		o := &obs[i]
+		if o.counter {
+			o.inst, err = meter.Int64ObservableCounter(o.name, metric.WithDescription(o.desc))
+		} else {
+			o.inst, err = meter.Int64ObservableGauge(o.name, metric.WithDescription(o.desc))
+		}
+		if err != nil {
+			return nil, err
+		}
+		insts[i] = o.inst
+	}
+
+	reg, err := meter.RegisterCallback(func(ctx context.Context, o metric.Observer) error {
+		for _, l := range m.Metrics() {
+			l := l
+			attrs := metric.WithAttributes(LimiterKey.String(string(l.Name)))
+			for _, ob := range obs {
+				o.ObserveInt64(ob.inst, ob.v(&l), attrs)
+			}
+		}
+		return nil
+	}, insts...)
+	if err != nil {
+		return nil, err
+	}
+
+	wait, err := meter.Float64Histogram("grmgr.wait.duration", metric.WithUnit("s"), metric.WithDescription("Time spent waiting in Control() for a slot."))
+	if err != nil {
+		reg.Unregister()
+		return nil, err
+	}
+	m.SetWaitTracer(&tracer{tracer: c.tp.Tracer(instrumentationName), wait: wait})
+
+	return func() error {
+		m.SetWaitTracer(nil)
+		return reg.Unregister()
+	}, nil
+}
+
+// tracer implements grmgr.WaitTracer.
+type tracer struct {
+	tracer trace.Tracer
+	wait   metric.Float64Histogram
+}
+
+func (t *tracer) StartWait(ctx context.Context, limiter grmgr.Routine, n int) func(err error) {
+
+	attr := LimiterKey.String(string(limiter))
+	ctx, span := t.tracer.Start(ctx, "grmgr.wait", trace.WithAttributes(attr, attribute.Int("grmgr.units", n)))
+	t0 := time.Now()
+
+	return func(err error) {
+		t.wait.Record(ctx, time.Since(t0).Seconds(), metric.WithAttributes(attr))
+		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+		}
+		span.End()
+	}
+}
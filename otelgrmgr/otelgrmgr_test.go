@@ -0,0 +1,101 @@
+package otelgrmgr
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ros2hp/grmgr"
+	"go.opentelemetry.io/otel/attribute"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestInstrument(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	m := grmgr.NewManager()
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		m.Run(ctx)
+	}()
+	defer func() {
+		cancel()
+		wg.Wait()
+	}()
+
+	reader := sdkmetric.NewManualReader()
+	mp := sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader))
+	spans := tracetest.NewSpanRecorder()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(spans))
+
+	stop, err := Instrument(m, WithMeterProvider(mp), WithTracerProvider(tp))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stop()
+
+	l, _ := m.NewConfig("loader", 1, 1, 1, 1, "0s")
+	l.Control()
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		l.Done()
+	}()
+	l.Control() // waits for a slot
+	l.Done()
+	l.Wait()
+
+	// a span for each wait, tagged with the limiter
+	ended := spans.Ended()
+	if len(ended) != 2 {
+		t.Fatalf("expected 2 spans got %d", len(ended))
+	}
+	for _, s := range ended {
+		if s.Name() != "grmgr.wait" || !hasAttr(s.Attributes(), LimiterKey.String("loader")) {
+			t.Errorf("unexpected span %s %v", s.Name(), s.Attributes())
+		}
+	}
+	if d := ended[1].EndTime().Sub(ended[1].StartTime()); d < 5*time.Millisecond {
+		t.Errorf("expected second span to cover the wait got %s", d)
+	}
+
+	var rm metricdata.ResourceMetrics
+	if err := reader.Collect(context.Background(), &rm); err != nil {
+		t.Fatal(err)
+	}
+	got := map[string]int64{}
+	var waits uint64
+	for _, sm := range rm.ScopeMetrics {
+		for _, md := range sm.Metrics {
+			switch d := md.Data.(type) {
+			case metricdata.Gauge[int64]:
+				got[md.Name] = d.DataPoints[0].Value
+			case metricdata.Sum[int64]:
+				got[md.Name] = d.DataPoints[0].Value
+			case metricdata.Histogram[float64]:
+				waits = d.DataPoints[0].Count
+			}
+		}
+	}
+	for name, want := range map[string]int64{"grmgr.ceiling": 1, "grmgr.active": 0, "grmgr.asks": 2, "grmgr.completions": 2} {
+		if got[name] != want {
+			t.Errorf("expected %s %d got %d", name, want, got[name])
+		}
+	}
+	if waits != 2 {
+		t.Errorf("expected 2 wait durations got %d", waits)
+	}
+}
+
+func hasAttr(attrs []attribute.KeyValue, kv attribute.KeyValue) bool {
+	for _, a := range attrs {
+		if a == kv {
+			return true
+		}
+	}
+	return false
+}
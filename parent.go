@@ -0,0 +1,79 @@
+package grmgr
+
+import (
+	"fmt"
+	"math"
+)
+
+// A Limiter may be nested under a parent Limiter (LimiterConfig.Parent), whose ceiling is then a
+// budget shared by its children: a task is only granted a slot when it fits under the ceiling of
+// its Limiter and of every parent above it, and the units it holds count against each of them.
+// Freed budget is granted to the Limiters sharing it in turn, a slot at a time, so a busy child
+// cannot starve its siblings. Pausing a parent pauses its children. Parents may themselves be nested.
+//
+// A change to a parent's ceiling rescales each child's ceiling in proportion, i.e. to the child's
+// maximum times the parent's ceiling over the parent's maximum, within the child's bounds.
+// Up() and Down() of all Limiters therefore adjust only top level Limiters; children follow.
+//
+// A parent is typically used only as a budget. Routines may Control() it directly, however
+// children draw on its ceiling without regard to routines waiting on it. Fast Limiters can be
+// neither parents nor children.
+
+// attach nests l under the parent p.
+// Called by grmgr only.
+func (l *Limiter) attach(p *Limiter) {
+	l.parent = p
+	p.children = append(p.children, l)
+	logAlert(fmt.Sprintf("Routine %s shares the budget of %s [ceiling: %d]", l.r, p.r, p.c))
+}
+
+// detach removes l from its parent, returning any units it holds to the parent's budget, and
+// makes its children top level Limiters.
+// Called by grmgr only.
+func (l *Limiter) detach() {
+
+	if p := l.parent; p != nil {
+		for i, c := range p.children {
+			if c == l {
+				p.children = append(p.children[:i], p.children[i+1:]...)
+				break
+			}
+		}
+		p.add(-l.rCnt)
+		l.parent = nil
+		p.release()
+	}
+	for _, c := range l.children {
+		c.parent = nil
+	}
+	l.children = nil
+}
+
+// complete counts a completed task against l and its parents.
+// Called by grmgr only.
+func (l *Limiter) complete() {
+	for x := l; x != nil; x = x.parent {
+		x.completed++
+		x.metrics.completions.Add(1)
+	}
+}
+
+// scaleChildren rescales the ceiling of each child in proportion to the Limiter's ceiling.
+// Called by grmgr only.
+func (l *Limiter) scaleChildren() {
+
+	for _, ch := range l.children {
+		c := int(math.Round(float64(ch.maxc) * float64(l.c) / float64(l.maxc)))
+		if c < ch.minc {
+			c = ch.minc
+		}
+		if c > ch.maxc {
+			c = ch.maxc
+		}
+		if c == ch.c {
+			continue
+		}
+		logAlert(fmt.Sprintf("%s ceiling set to %d following parent %s [was: %d]", ch.or, c, l.r, ch.c))
+		ch.setC(c)
+	}
+}
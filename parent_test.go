@@ -0,0 +1,162 @@
+package grmgr
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestParentBudget(t *testing.T) {
+	m, _ := startManager(t)
+	if _, err := m.NewLimiter(LimiterConfig{Name: "budget", Ceiling: 4, Min: 1}); err != nil {
+		t.Fatal(err)
+	}
+	var children []*Limiter
+	for _, name := range []string{"loader", "writer"} {
+		l, err := m.NewLimiter(LimiterConfig{Name: name, Ceiling: 4, Min: 1, Parent: "budget"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		children = append(children, l)
+	}
+
+	// each child alone may use 4, together no more than 4
+	var cur, peak int64
+	var wg sync.WaitGroup
+	for _, l := range children {
+		wg.Add(1)
+		go func(l *Limiter) {
+			defer wg.Done()
+			for i := 0; i < 40; i++ {
+				l.Control()
+				go func() {
+					v := atomic.AddInt64(&cur, 1)
+					for {
+						p := atomic.LoadInt64(&peak)
+						if v <= p || atomic.CompareAndSwapInt64(&peak, p, v) {
+							break
+						}
+					}
+					time.Sleep(time.Millisecond)
+					atomic.AddInt64(&cur, -1)
+					l.Done()
+				}()
+			}
+			l.Wait()
+		}(l)
+	}
+	wg.Wait()
+	if peak > 4 {
+		t.Errorf("expected at most 4 concurrent tasks across children got %d", peak)
+	}
+
+	st := m.Status()
+	if st[0].Name != "budget" || st[0].Active != 0 || st[1].Parent != "budget" {
+		t.Errorf("unexpected status %+v", st)
+	}
+}
+
+func TestParentScalesChildren(t *testing.T) {
+	m, _ := startManager(t)
+	p, _ := m.NewLimiter(LimiterConfig{Name: "budget", Ceiling: 8, Min: 1})
+	a, _ := m.NewLimiter(LimiterConfig{Name: "loader", Ceiling: 6, Min: 2, Parent: "budget"})
+	b, _ := m.NewLimiter(LimiterConfig{Name: "writer", Ceiling: 2, Min: 1, Parent: "budget"})
+
+	if err := p.SetCeiling(4); err != nil {
+		t.Fatal(err)
+	}
+	ceilings := func() map[Routine]Ceiling {
+		c := make(map[Routine]Ceiling)
+		for _, s := range m.Status() {
+			c[s.Name] = s.Ceiling
+		}
+		return c
+	}
+	if c := ceilings(); c["loader"] != 3 || c["writer"] != 1 {
+		t.Errorf("expected children halved to 3 and 1 got %v", c)
+	}
+	if err := p.SetCeiling(1); err != nil {
+		t.Fatal(err)
+	}
+	if c := ceilings(); c["loader"] != 2 || c["writer"] != 1 {
+		t.Errorf("expected children at their minimum got %v", c)
+	}
+
+	// a paused parent pauses its children
+	p.SetCeiling(8)
+	p.Pause()
+	if a.TryControl() || b.TryControl() {
+		t.Error("expected children paused with their parent")
+	}
+	p.Resume()
+	if !a.TryControl() {
+		t.Error("expected child resumed with its parent")
+	}
+	a.Done()
+
+	// a child leaving returns its units to the parent
+	a.TryControl()
+	a.Unregister()
+	if st := m.Status(); st[0].Active != 0 {
+		t.Errorf("expected parent budget returned got %+v", st[0])
+	}
+}
+
+func TestParentInvalid(t *testing.T) {
+	m, _ := startManager(t)
+	m.NewFast("fast", 2)
+
+	for _, cfg := range []LimiterConfig{
+		{Name: "loader", Ceiling: 2, Parent: "missing"},
+		{Name: "loader", Ceiling: 2, Parent: "fast"},
+		{Name: "loader", Ceiling: 2, Parent: "fast", Fast: true},
+	} {
+		if _, err := m.NewLimiter(cfg); !errors.Is(err, ErrParent) {
+			t.Errorf("expected ErrParent for %+v got %v", cfg, err)
+		}
+	}
+	if st := m.Status(); len(st) != 1 {
+		t.Errorf("expected invalid limiters not registered got %+v", st)
+	}
+}
+
+func TestParentSiblingsNotStarved(t *testing.T) {
+	m, _ := startManager(t)
+	m.NewLimiter(LimiterConfig{Name: "budget", Ceiling: 2, Min: 1})
+	a, _ := m.NewLimiter(LimiterConfig{Name: "loader", Ceiling: 2, Min: 1, Parent: "budget"})
+	b, _ := m.NewLimiter(LimiterConfig{Name: "writer", Ceiling: 2, Min: 1, Parent: "budget"})
+
+	// keep the budget saturated with a steady queue on one child
+	var wg sync.WaitGroup
+	stop := make(chan struct{})
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-stop:
+					return
+				default:
+				}
+				a.Control()
+				time.Sleep(time.Millisecond)
+				a.Done()
+			}
+		}()
+	}
+	time.Sleep(20 * time.Millisecond)
+
+	for i := 0; i < 3; i++ {
+		if err := b.ControlTimeout(500 * time.Millisecond); err != nil {
+			t.Errorf("sibling starved: %v", err)
+			break
+		}
+		b.Done()
+	}
+	close(stop)
+	wg.Wait()
+	a.Wait()
+}
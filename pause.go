@@ -0,0 +1,44 @@
+package grmgr
+
+// Pause stops the Limiter granting slots without changing its configuration. Routines calling
+// Control() and its variants wait, in their usual order, and TryControl() fails, while tasks already
+// running finish and call Done() as usual. Up(), Down(), SetCeiling() etc. still apply, taking effect
+// on Resume(). Automatic scaling is suspended while paused. Pausing a paused Limiter has no effect.
+func (l *Limiter) Pause() error {
+	return l.set(setPause, 0, 0)
+}
+
+// Resume grants slots again, to waiting routines first, up to the Limiter's current ceiling.
+func (l *Limiter) Resume() error {
+	return l.set(setResume, 0, 0)
+}
+
+// Pause pauses all Limiters registered with m. See Limiter.Pause.
+func (m *Manager) Pause() error {
+	return m.setAll(setPause)
+}
+
+// Resume resumes all Limiters registered with m.
+func (m *Manager) Resume() error {
+	return m.setAll(setResume)
+}
+
+func (m *Manager) setAll(op setOp) error {
+	req := setReq{r: Routine("__all"), op: op, err: make(chan error, 1)}
+	select {
+	case m.setCh <- req:
+	case <-m.powerOffCh:
+		return ErrPoweredOff
+	}
+	return <-req.err
+}
+
+// Pause pauses all Limiters of the default grmgr service.
+func (t throttle_) Pause() error {
+	return Default().Pause()
+}
+
+// Resume resumes all Limiters of the default grmgr service.
+func (t throttle_) Resume() error {
+	return Default().Resume()
+}
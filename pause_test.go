@@ -0,0 +1,79 @@
+package grmgr
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestPause(t *testing.T) {
+	m, _ := startManager(t)
+
+	for _, fast := range []bool{false, true} {
+		l, err := m.NewLimiter(LimiterConfig{Name: "loader", Ceiling: 2, Min: 1, Up: 1, Down: 1, Fast: fast})
+		if err != nil {
+			t.Fatal(err)
+		}
+		l.Control()
+		if err := l.Pause(); err != nil {
+			t.Fatal(err)
+		}
+		if err := l.ControlTimeout(20 * time.Millisecond); !errors.Is(err, ErrTimedOut) {
+			t.Errorf("fast %v: expected no slot while paused got %v", fast, err)
+		}
+		if l.TryControl() {
+			t.Errorf("fast %v: expected TryControl to fail while paused", fast)
+		}
+
+		granted := make(chan struct{})
+		go func() {
+			l.Control()
+			close(granted)
+		}()
+		// the running task finishes while paused
+		l.Done()
+		select {
+		case <-granted:
+			t.Fatalf("fast %v: expected waiting routine to wait while paused", fast)
+		case <-time.After(50 * time.Millisecond):
+		}
+		if st := m.Status(); !st[0].Paused || st[0].Ceiling != 2 {
+			t.Errorf("fast %v: expected paused at ceiling 2 got %+v", fast, st)
+		}
+
+		if err := l.Resume(); err != nil {
+			t.Fatal(err)
+		}
+		select {
+		case <-granted:
+		case <-time.After(time.Second):
+			t.Fatalf("fast %v: expected waiting routine granted on resume", fast)
+		}
+		l.Done()
+		l.Unregister()
+	}
+}
+
+func TestPauseAll(t *testing.T) {
+	m, _ := startManager(t)
+	a, _ := m.NewConfig("loader", 2, 1, 1, 1, "0s")
+	b := m.NewFast("writer", 2)
+
+	if err := m.Pause(); err != nil {
+		t.Fatal(err)
+	}
+	for _, l := range []*Limiter{a, b} {
+		if l.TryControl() {
+			t.Errorf("expected %s paused", l.Routine())
+		}
+	}
+	if err := m.Resume(); err != nil {
+		t.Fatal(err)
+	}
+	for _, l := range []*Limiter{a, b} {
+		if !l.TryControl() {
+			t.Errorf("expected %s resumed", l.Routine())
+		}
+		l.Done()
+	}
+}
@@ -0,0 +1,256 @@
+package grmgr
+
+import (
+	"fmt"
+	"math"
+	"time"
+)
+
+// Step is one step of a scaling Profile. Exactly one of Factor, Delta or Set adjusts the ceiling.
+type Step struct {
+	Factor float64       // multiply the ceiling e.g. 0.5 halves it
+	Delta  int           // add to the ceiling, negative values reduce it
+	Set    Ceiling       // set the ceiling to an absolute value
+	Hold   time.Duration // wait before the next step, or before repeating this one
+	Repeat bool          // repeat the step until the ceiling stops changing i.e. reaches its minimum or maximum
+}
+
+// Profile is a named sequence of scaling steps, e.g. "halve now, then +1 every 30s until max":
+//
+//	grmgr.Profile{Name: "recover", Steps: []grmgr.Step{
+//		{Factor: 0.5, Hold: 30 * time.Second},
+//		{Delta: 1, Hold: 30 * time.Second, Repeat: true},
+//	}}
+//
+// The ceiling is kept within the Limiter's minimum and maximum. Steps are not subject to the
+// Limiter's hold period.
+type Profile struct {
+	Name  string
+	Steps []Step
+}
+
+func (p Profile) validate() error {
+	if len(p.Name) == 0 {
+		return fmt.Errorf("profile must have a name")
+	}
+	if len(p.Steps) == 0 {
+		return fmt.Errorf("profile %q has no steps", p.Name)
+	}
+	for i, s := range p.Steps {
+		n := 0
+		if s.Factor != 0 {
+			n++
+		}
+		if s.Delta != 0 {
+			n++
+		}
+		if s.Set != 0 {
+			n++
+		}
+		if n != 1 {
+			return fmt.Errorf("profile %q step %d: exactly one of Factor, Delta or Set must be specified", p.Name, i)
+		}
+		if s.Factor < 0 || s.Set < 0 || s.Hold < 0 {
+			return fmt.Errorf("profile %q step %d: negative Factor, Set or Hold", p.Name, i)
+		}
+	}
+	return nil
+}
+
+// next returns the ceiling after applying the step to c.
+func (s Step) next(c Ceiling) Ceiling {
+	switch {
+	case s.Set != 0:
+		return s.Set
+	case s.Delta != 0:
+		return c + s.Delta
+	}
+	n := Ceiling(math.Round(float64(c) * s.Factor))
+	if n == c {
+		// always make progress
+		if s.Factor > 1 {
+			n++
+		} else if s.Factor < 1 {
+			n--
+		}
+	}
+	return n
+}
+
+type profileOp byte
+
+const (
+	registerProfile profileOp = iota
+	applyProfile
+	findProfile
+)
+
+type profileReq struct {
+	op   profileOp
+	p    Profile
+	name string
+	r    Routine // limiter to apply profile to, "__all" for all
+	err  chan error
+}
+
+// profileRun is a profile in progress on a Limiter.
+type profileRun struct {
+	p    *Profile
+	step int
+	next time.Time // when the step is due
+}
+
+// RegisterProfile registers a scaling profile with the default grmgr service.
+func RegisterProfile(p Profile) error {
+	return Default().RegisterProfile(p)
+}
+
+// RegisterProfile registers a scaling profile by name, replacing any profile of the same name.
+func (m *Manager) RegisterProfile(p Profile) error {
+	if err := p.validate(); err != nil {
+		return err
+	}
+	p.Steps = append([]Step(nil), p.Steps...)
+	return m.profile(profileReq{op: registerProfile, p: p})
+}
+
+// ApplyProfile runs the named profile on all Limiters of m. An empty name stops running profiles.
+func (m *Manager) ApplyProfile(name string) error {
+	return m.profile(profileReq{op: applyProfile, name: name, r: Routine("__all")})
+}
+
+// ApplyProfile runs the named profile on the Limiter, replacing any profile in progress.
+// An empty name stops a running profile.
+func (l *Limiter) ApplyProfile(name string) error {
+	return l.m.profile(profileReq{op: applyProfile, name: name, r: l.r})
+}
+
+func (t throttle_) ApplyProfile(name string) error {
+	return Default().ApplyProfile(name)
+}
+
+func (m *Manager) profile(req profileReq) error {
+	req.err = make(chan error, 1)
+	select {
+	case m.profileCh <- req:
+	case <-m.powerOffCh:
+		return ErrPoweredOff
+	}
+	return <-req.err
+}
+
+// doProfile processes a profile request.
+// Called by grmgr only.
+func (m *Manager) doProfile(req profileReq, now time.Time) error {
+
+	switch req.op {
+
+	case registerProfile:
+		p := req.p
+		m.profiles[p.Name] = &p
+		logAlert(fmt.Sprintf("RegisterProfile: %q [steps: %d]", p.Name, len(p.Steps)))
+
+	case findProfile:
+		if _, ok := m.profiles[req.name]; !ok {
+			return fmt.Errorf("profile %q not registered", req.name)
+		}
+
+	case applyProfile:
+		var p *Profile
+		if len(req.name) > 0 {
+			var ok bool
+			if p, ok = m.profiles[req.name]; !ok {
+				return fmt.Errorf("ApplyProfile: profile %q not registered", req.name)
+			}
+		}
+		allr := make(rLimiterMap)
+		if req.r == Routine("__all") {
+			allr = m.rLimit
+		} else if l, ok := m.rLimit[req.r]; ok {
+			allr[req.r] = l
+		} else {
+			return &ControlError{Limiter: req.r, Err: ErrNotRegistered}
+		}
+		for _, l := range allr {
+			if p == nil {
+				l.prof = nil
+				continue
+			}
+			l.prof = &profileRun{p: p, next: now}
+			logAlert(fmt.Sprintf("ApplyProfile: %q to %s", p.Name, l.or))
+		}
+		m.runProfiles(now)
+	}
+	return nil
+}
+
+// runProfiles applies the steps that are due and schedules the profile timer for the next one.
+// Called by grmgr only.
+func (m *Manager) runProfiles(now time.Time) {
+
+	var due time.Time
+
+	for _, l := range m.rLimit {
+		pr := l.prof
+		for pr != nil && !now.Before(pr.next) {
+			s := pr.p.Steps[pr.step]
+			c := s.next(l.c)
+			if c < l.minc {
+				c = l.minc
+			}
+			if c > l.maxc {
+				c = l.maxc
+			}
+			changed := c != l.c
+			if changed {
+				if c < l.c {
+					l.throttleDownActioned = now
+				} else {
+					l.throttleUpActioned = now
+				}
+				logAlert(fmt.Sprintf("profile %q: %s ceiling set to %d [step: %d]", pr.p.Name, l.or, c, pr.step))
+				l.setC(c)
+			}
+			if !s.Repeat || !changed {
+				pr.step++
+			}
+			if pr.step == len(pr.p.Steps) {
+				logAlert(fmt.Sprintf("profile %q: completed for %s", pr.p.Name, l.or))
+				l.prof, pr = nil, nil
+				break
+			}
+			if changed || !s.Repeat {
+				pr.next = now.Add(s.Hold)
+			}
+		}
+		if pr != nil && (due.IsZero() || pr.next.Before(due)) {
+			due = pr.next
+		}
+	}
+
+	if due.IsZero() {
+		if m.profTimer != nil {
+			m.profTimer.Stop()
+		}
+		return
+	}
+	if m.profTimer == nil {
+		m.profTimer = time.NewTimer(due.Sub(now))
+		return
+	}
+	if !m.profTimer.Stop() {
+		select {
+		case <-m.profTimer.C:
+		default:
+		}
+	}
+	m.profTimer.Reset(due.Sub(now))
+}
+
+// profileC returns the profile timer channel, nil when no profile is running.
+func (m *Manager) profileC() <-chan time.Time {
+	if m.profTimer == nil {
+		return nil
+	}
+	return m.profTimer.C
+}
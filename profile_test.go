@@ -0,0 +1,88 @@
+package grmgr
+
+import (
+	"testing"
+	"time"
+)
+
+func TestProfileValidate(t *testing.T) {
+	for i, p := range []Profile{
+		{Steps: []Step{{Delta: 1}}},
+		{Name: "empty"},
+		{Name: "none", Steps: []Step{{Hold: time.Second}}},
+		{Name: "two", Steps: []Step{{Delta: 1, Set: 2}}},
+		{Name: "negative", Steps: []Step{{Factor: -1}}},
+	} {
+		if err := p.validate(); err == nil {
+			t.Errorf("%d: expected error for profile %q", i, p.Name)
+		}
+	}
+}
+
+func TestRunProfile(t *testing.T) {
+	m := NewManager()
+	l := &Limiter{m: m, r: "p", or: "p", c: 8, maxc: 8, minc: 1, ch: make(respCh)}
+	m.rLimit[l.r] = l
+	m.profiles["recover"] = &Profile{Name: "recover", Steps: []Step{
+		{Factor: 0.5, Hold: 30 * time.Second},
+		{Delta: 1, Hold: 30 * time.Second, Repeat: true},
+	}}
+	t0 := time.Now()
+
+	if err := m.doProfile(profileReq{op: applyProfile, name: "recover", r: l.r}, t0); err != nil {
+		t.Fatal(err)
+	}
+	if l.c != 4 {
+		t.Fatalf("expected ceiling halved to 4 got %d", l.c)
+	}
+	m.runProfiles(t0.Add(10 * time.Second))
+	if l.c != 4 {
+		t.Fatalf("expected ceiling held at 4 got %d", l.c)
+	}
+	for i, want := range []Ceiling{5, 6, 7, 8, 8} {
+		m.runProfiles(t0.Add(time.Duration(i+1) * 30 * time.Second))
+		if l.c != want {
+			t.Fatalf("%d: expected ceiling %d got %d", i, want, l.c)
+		}
+	}
+	if l.prof != nil {
+		t.Error("expected profile completed at maximum")
+	}
+	if err := m.doProfile(profileReq{op: applyProfile, name: "missing", r: l.r}, t0); err == nil {
+		t.Error("expected error for unregistered profile")
+	}
+	m.profTimer.Stop()
+}
+
+func TestApplyProfile(t *testing.T) {
+	m, _ := startManager(t)
+	a, _ := m.NewConfig("pa", 8, 1, 1, 1, "1h")
+	b, _ := m.NewConfig("pb", 6, 1, 1, 1, "1h")
+
+	if err := m.RegisterProfile(Profile{Name: "drop", Steps: []Step{{Set: 2, Hold: 10 * time.Millisecond}, {Delta: 2}}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := a.ApplyProfile("drop"); err != nil {
+		t.Fatal(err)
+	}
+	if n := slots(a); n != 2 && n != 4 {
+		t.Fatalf("expected ceiling set to 2 got %d", n)
+	}
+	time.Sleep(50 * time.Millisecond)
+	if n := slots(a); n != 4 {
+		t.Fatalf("expected second step to raise ceiling to 4 got %d", n)
+	}
+	if n := slots(b); n != 6 {
+		t.Fatalf("expected other limiter unaffected got %d", n)
+	}
+
+	if err := m.RegisterProfile(Profile{Name: "halve", Steps: []Step{{Factor: 0.5}}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.ApplyProfile("halve"); err != nil {
+		t.Fatal(err)
+	}
+	if na, nb := slots(a), slots(b); na != 2 || nb != 3 {
+		t.Fatalf("expected all limiters halved to 2 and 3 got %d and %d", na, nb)
+	}
+}
@@ -0,0 +1,274 @@
+package grmgr
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// waiter is a routine waiting for n units of a limiter's ceiling. Each waiter has its own
+// response channel, so grmgr can grant slots in a defined order.
+type waiter struct {
+	r   Routine
+	n   int    // units of the ceiling
+	p   int    // priority, higher is served first
+	seq uint64 // arrival order, assigned by grmgr
+	ch  chan struct{}
+	// turned away by a draining grmgr. Maintained by grmgr
+	rejected bool
+	// counted in the limiter's WaitGroup. Maintained by grmgr
+	counted bool
+}
+
+// waitQueue is ordered by priority then arrival (FIFO).
+type waitQueue []*waiter
+
+func (q *waitQueue) push(w *waiter) {
+	i := sort.Search(len(*q), func(i int) bool {
+		v := (*q)[i]
+		return v.p < w.p || (v.p == w.p && v.seq > w.seq)
+	})
+	*q = append(*q, nil)
+	copy((*q)[i+1:], (*q)[i:])
+	(*q)[i] = w
+}
+
+func (q *waitQueue) remove(i int) *waiter {
+	w := (*q)[i]
+	*q = append((*q)[:i], (*q)[i+1:]...)
+	return w
+}
+
+func (q waitQueue) index(w *waiter) int {
+	for i, v := range q {
+		if v == w {
+			return i
+		}
+	}
+	return -1
+}
+
+// ControlPriority is Control() for a task of priority p. Waiting tasks of higher priority are
+// granted slots before those of lower priority; tasks of equal priority are granted in arrival order.
+// Control() has priority 0.
+func (l *Limiter) ControlPriority(p int) {
+	l.controlWait(1, p)
+}
+
+// controlWait is control() for Control() and the other variants that return no error. Their
+// callers cannot tell they were refused a slot, so would run their task and call Done(). A routine
+// turned away by a draining grmgr therefore waits until grmgr powers off, after which Done() only
+// releases Wait(). The routine is counted by Wait() for the duration of the call, so a refused
+// routine stays counted, to be matched by its Done(), without adding to the WaitGroup once grmgr
+// may have powered off.
+func (l *Limiter) controlWait(n int, p int) {
+	l.wg.Add(1)
+	err := l.control(context.Background(), n, p)
+	if errors.Is(err, ErrShuttingDown) {
+		<-l.m.powerOffCh
+		err = &ControlError{Limiter: l.r, Err: ErrPoweredOff}
+	}
+	if err != nil {
+		logErr(err)
+		return
+	}
+	// counted by the grant
+	l.wg.Done()
+}
+
+func (l *Limiter) control(ctx context.Context, n int, p int) error {
+
+	l.metrics.asks.Add(1)
+	if l.fast && l.fastTry(n) {
+		// granted without waiting
+		l.metrics.observeWait(0)
+		return nil
+	}
+	t0 := time.Now()
+	var end func(error)
+	if t := l.m.waitTracer(); t != nil {
+		end = t.StartWait(ctx, l.r, n)
+	}
+	var err error
+	if l.fast {
+		err = l.fastControl(ctx, n, p)
+	} else {
+		err = l.queue(ctx, n, p)
+	}
+	if err == nil {
+		l.metrics.observeWait(time.Since(t0))
+	}
+	if end != nil {
+		end(err)
+	}
+	return err
+}
+
+// queue requests n units from grmgr, waiting in the limiter's queue until they are granted.
+// The routine is counted by Wait() only if it is granted the units.
+func (l *Limiter) queue(ctx context.Context, n int, p int) error {
+
+	w := &waiter{r: l.r, n: n, p: p, ch: make(chan struct{}, 1)}
+	select {
+	case l.m.rWaitCh <- w:
+	case <-ctx.Done():
+		return l.ctxErr(ctx)
+	case <-l.m.drainCh:
+		return &ControlError{Limiter: l.r, Err: ErrShuttingDown}
+	case <-l.m.powerOffCh:
+		return &ControlError{Limiter: l.r, Err: ErrPoweredOff}
+	}
+
+	select {
+	case <-w.ch:
+		return nil
+	case <-l.m.drainCh:
+		// a slot granted before draining started stands
+		select {
+		case <-w.ch:
+			return nil
+		default:
+		}
+		return &ControlError{Limiter: l.r, Err: ErrShuttingDown}
+	case <-ctx.Done():
+		// grmgr hands back the slot if it was granted in the meantime
+		select {
+		case l.m.rWithdrawCh <- w:
+		case <-l.m.powerOffCh:
+			l.uncount(w)
+		}
+		return l.ctxErr(ctx)
+	case <-l.m.powerOffCh:
+		l.uncount(w)
+		return &ControlError{Limiter: l.r, Err: ErrPoweredOff}
+	}
+}
+
+// uncount removes a waiter left with grmgr at power off from Wait().
+func (l *Limiter) uncount(w *waiter) {
+	// counted is stable once grmgr has powered off
+	if w.counted {
+		l.wg.Done()
+	}
+}
+
+// fits reports whether n units can be granted against the current ceiling, and that of each parent.
+// A weight above the ceiling fits when idle. Nothing fits while paused or at a ceiling of 0.
+func (l *Limiter) fits(n int) bool {
+	for x := l; x != nil; x = x.parent {
+		if x.paused || !(x.rCnt+n <= x.c || (x.rCnt == 0 && n > x.c && x.c > 0)) {
+			return false
+		}
+	}
+	return true
+}
+
+// ask grants the waiter a slot immediately if nothing is queued ahead of it, otherwise queues it.
+// Called by grmgr only.
+func (l *Limiter) ask(w *waiter) {
+
+	if l.m.draining {
+		w.rejected = true
+		return
+	}
+	l.wg.Add(1)
+	w.counted = true
+
+	if len(l.waitq) == 0 && l.fits(w.n) {
+		l.add(w.n)
+		w.ch <- struct{}{} // proceed to run gr
+		logDebug(fmt.Sprintf("has ASKed. Under cnt limit. SEnt ACK on routine channel..for %s  cnt: %d Limit: %d", w.r, l.rCnt, l.c))
+		return
+	}
+	logDebug(fmt.Sprintf("has ASKed %s. Cnt [%d] is above limit [%d]. Mark %s as waiting", w.r, l.rCnt, l.c, w.r))
+	l.m.seq++
+	w.seq = l.m.seq
+	l.waitq.push(w)
+	l.release()
+}
+
+// withdraw removes a waiter that gave up. If it had already been granted its units are released.
+// Called by grmgr only.
+func (l *Limiter) withdraw(w *waiter) {
+
+	if w.rejected {
+		return
+	}
+	l.wg.Done()
+	if i := l.waitq.index(w); i >= 0 {
+		l.waitq.remove(i)
+		return
+	}
+	l.add(-w.n)
+	l.release()
+}
+
+// release grants freed capacity to waiting routines. As capacity freed in a Limiter may be drawn on
+// by any Limiter sharing its budget, the Limiters beneath the topmost parent are granted a slot
+// each in turn, round robin, so a Limiter with a steady queue cannot starve its siblings.
+// Called by grmgr only.
+func (l *Limiter) release() {
+	root := l
+	for root.parent != nil {
+		root = root.parent
+	}
+	if len(root.children) == 0 {
+		for root.grant() {
+		}
+		return
+	}
+	tree := root.tree(nil)
+	for granted := true; granted; {
+		granted = false
+		for i := range tree {
+			j := (root.next + i) % len(tree)
+			if tree[j].grant() {
+				root.next, granted = (j+1)%len(tree), true
+				break
+			}
+		}
+	}
+}
+
+// tree appends l and the Limiters beneath it to t.
+func (l *Limiter) tree(t []*Limiter) []*Limiter {
+	t = append(t, l)
+	for _, c := range l.children {
+		t = c.tree(t)
+	}
+	return t
+}
+
+// grant grants freed capacity to the next waiting routine in queue order, reporting whether it
+// did. When the head of the queue does not fit (it is a weighted waiter) later waiters that fit
+// may overtake it, but only maxc times, after which capacity is held back until the head fits.
+// This stops a stream of light tasks from starving a heavy one.
+// Called by grmgr only.
+func (l *Limiter) grant() bool {
+
+	if len(l.waitq) == 0 {
+		return false
+	}
+	i := 0
+	if !l.fits(l.waitq[0].n) {
+		if l.bypass >= l.maxc {
+			// reserve capacity for head of queue
+			return false
+		}
+		for i = 1; i < len(l.waitq) && !l.fits(l.waitq[i].n); i++ {
+		}
+		if i == len(l.waitq) {
+			return false
+		}
+		l.bypass++
+	} else {
+		l.bypass = 0
+	}
+	w := l.waitq.remove(i)
+	l.add(w.n)
+	// Send ack to waiting routine
+	w.ch <- struct{}{}
+	return true
+}
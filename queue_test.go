@@ -0,0 +1,129 @@
+package grmgr
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func newWaiter(n, p int) *waiter {
+	return &waiter{n: n, p: p, ch: make(chan struct{}, 1)}
+}
+
+func granted(w *waiter) bool {
+	select {
+	case <-w.ch:
+		return true
+	default:
+		return false
+	}
+}
+
+// TestWaitQueueOrder drives the grmgr side of a limiter directly.
+func TestWaitQueueOrder(t *testing.T) {
+	l := &Limiter{m: NewManager(), c: 1, maxc: 1}
+
+	first := newWaiter(1, 0)
+	l.ask(first)
+	if !granted(first) {
+		t.Fatal("expected immediate grant under ceiling")
+	}
+
+	bg1, bg2, urgent := newWaiter(1, 0), newWaiter(1, 0), newWaiter(1, 5)
+	l.ask(bg1)
+	l.ask(bg2)
+	l.ask(urgent)
+
+	for i, want := range []*waiter{urgent, bg1, bg2} {
+		l.rCnt--
+		l.release()
+		if !granted(want) {
+			t.Fatalf("grant %d: expected waiter of priority %d, seq %d", i, want.p, want.seq)
+		}
+		if len(l.waitq) != 2-i {
+			t.Fatalf("grant %d: expected %d waiting got %d", i, 2-i, len(l.waitq))
+		}
+	}
+}
+
+func TestWaitQueueWithdraw(t *testing.T) {
+	l := &Limiter{m: NewManager(), c: 1, maxc: 1}
+
+	w0, w1, w2 := newWaiter(1, 0), newWaiter(1, 0), newWaiter(1, 0)
+	l.ask(w0)
+	l.ask(w1)
+	l.ask(w2)
+
+	// w1 gives up while queued
+	l.withdraw(w1)
+	// w0 gives up after it was granted, handing its slot to w2
+	l.withdraw(w0)
+	if !granted(w2) || granted(w1) {
+		t.Fatal("expected slot to pass to w2")
+	}
+	if l.rCnt != 1 || len(l.waitq) != 0 {
+		t.Errorf("expected 1 active 0 waiting got %d and %d", l.rCnt, len(l.waitq))
+	}
+}
+
+func TestWaitQueueWeightedHead(t *testing.T) {
+	l := &Limiter{m: NewManager(), c: 2, maxc: 2}
+
+	l.ask(newWaiter(1, 0))
+	heavy := newWaiter(2, 0)
+	l.ask(heavy)
+
+	light := []*waiter{newWaiter(1, 0), newWaiter(1, 0), newWaiter(1, 0)}
+	for _, w := range light {
+		l.ask(w)
+	}
+	// spare capacity goes to light waiters while the heavy head does not fit
+	if !granted(light[0]) || granted(heavy) {
+		t.Fatal("expected light waiter to use spare capacity")
+	}
+	l.rCnt--
+	l.release()
+	if !granted(light[1]) {
+		t.Fatal("expected second light waiter to overtake heavy waiter")
+	}
+	// light waiters have overtaken maxc times, so capacity is now reserved
+	l.rCnt--
+	l.release()
+	if granted(light[2]) || granted(heavy) {
+		t.Fatal("expected capacity reserved for heavy waiter")
+	}
+	l.rCnt--
+	l.release()
+	if !granted(heavy) || granted(light[2]) {
+		t.Fatal("expected heavy waiter granted ahead of light waiter")
+	}
+}
+
+func TestWaitQueueBlocked(t *testing.T) {
+	zero := &Limiter{m: NewManager(), c: 0, maxc: 2}
+	parent := &Limiter{m: NewManager(), c: 2, maxc: 2, paused: true}
+	child := &Limiter{m: parent.m, c: 2, maxc: 2}
+	child.attach(parent)
+
+	for name, l := range map[string]*Limiter{"ceiling 0": zero, "paused parent": child} {
+		light, heavy := newWaiter(1, 0), newWaiter(3, 0)
+		l.ask(light)
+		l.ask(heavy)
+		if granted(light) || granted(heavy) {
+			t.Errorf("%s: expected every ask to wait", name)
+		}
+	}
+
+	// ControlTimeout is refused by a running grmgr at ceiling 0
+	m, _ := startManager(t)
+	l, err := m.NewLimiter(LimiterConfig{Name: "zero", Ceiling: 1, Min: 0, Up: 1, Down: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := l.SetCeiling(0); err != nil {
+		t.Fatal(err)
+	}
+	if err := l.ControlTimeout(20 * time.Millisecond); !errors.Is(err, ErrTimedOut) {
+		t.Errorf("expected ErrTimedOut at ceiling 0 got %v", err)
+	}
+}
@@ -0,0 +1,196 @@
+package grmgr
+
+import (
+	"fmt"
+	"os"
+	"os/signal"
+	"reflect"
+	"syscall"
+	"time"
+)
+
+// reloader watches the config file named by the Config key "configfile". The file is polled for
+// changes every "reloadinterval" (default 5s, 0 disables polling) and, when "sighup" is true,
+// reloaded on receipt of SIGHUP.
+type reloader struct {
+	path     string
+	interval time.Duration
+	sighup   bool
+	//
+	mod  time.Time // file modification time when last read
+	size int64
+	tick *time.Ticker
+	hup  chan os.Signal
+}
+
+func (rl *reloader) configure(k string, v interface{}) bool {
+
+	switch k {
+	case "configfile":
+		if s, ok := v.(string); ok && len(s) > 0 {
+			rl.path = s
+		} else {
+			logErr(fmt.Errorf("configfile should be a file path: %v", v))
+		}
+	case "reloadinterval":
+		d, err := toDuration(v)
+		if err != nil || d < 0 {
+			logErr(fmt.Errorf("reloadinterval should be a time.Duration or duration string: %v", v))
+			break
+		}
+		rl.interval = d
+	case "sighup":
+		if b, ok := v.(bool); ok {
+			rl.sighup = b
+		} else {
+			logErr(fmt.Errorf("sighup should be a bool: %v", v))
+		}
+	default:
+		return false
+	}
+	return true
+}
+
+func (rl *reloader) start() {
+
+	if len(rl.path) == 0 {
+		return
+	}
+	if rl.interval > 0 {
+		rl.tick = time.NewTicker(rl.interval)
+	}
+	if rl.sighup {
+		rl.hup = make(chan os.Signal, 1)
+		signal.Notify(rl.hup, syscall.SIGHUP)
+	}
+}
+
+func (rl *reloader) stop() {
+	if rl.tick != nil {
+		rl.tick.Stop()
+	}
+	if rl.hup != nil {
+		signal.Stop(rl.hup)
+	}
+}
+
+func (rl *reloader) tickC() <-chan time.Time {
+	if rl.tick == nil {
+		return nil
+	}
+	return rl.tick.C
+}
+
+func (rl *reloader) hupC() <-chan os.Signal {
+	return rl.hup
+}
+
+// changed reports whether the file has been modified since it was last read.
+func (rl *reloader) changed() bool {
+	fi, err := os.Stat(rl.path)
+	if err != nil {
+		logErr(fmt.Errorf("config file %s: %w", rl.path, err))
+		return false
+	}
+	return !fi.ModTime().Equal(rl.mod) || fi.Size() != rl.size
+}
+
+// reloadConfig reads the config file, registering new profiles and Limiters and applying changes to
+// the ceiling, bounds, steps and hold of Limiters already registered. Limiters no longer in the file
+// are left unchanged. An invalid file is logged and ignored.
+// Called by grmgr only.
+func (m *Manager) reloadConfig() {
+
+	rl := &m.reload
+	if fi, err := os.Stat(rl.path); err == nil {
+		rl.mod, rl.size = fi.ModTime(), fi.Size()
+	}
+	f, err := ReadConfigFile(rl.path)
+	if err != nil {
+		logErr(err)
+		return
+	}
+	hasProfile := func(name string) bool { _, ok := m.profiles[name]; return ok }
+	hasLimiter := func(name string) bool { _, ok := m.rLimit[Routine(name)]; return ok }
+
+	cfgs, profs, err := f.validate(hasProfile, hasLimiter)
+	if err != nil {
+		logErr(fmt.Errorf("config file %s: %w, configuration unchanged", rl.path, err))
+		return
+	}
+	now := time.Now()
+
+	for _, p := range profs {
+		p := p
+		if cur, ok := m.profiles[p.Name]; ok && reflect.DeepEqual(*cur, p) {
+			continue
+		}
+		m.profiles[p.Name] = &p
+		logAlert(fmt.Sprintf("reload: profile %q [steps: %d]", p.Name, len(p.Steps)))
+	}
+
+	for i, cfg := range cfgs {
+		if l, ok := m.rLimit[Routine(cfg.Name)]; ok {
+			l.reconfigure(cfg, now)
+			continue
+		}
+		l := newLimiter(m, cfg)
+		if err := m.register(l); err != nil {
+			logErr(fmt.Errorf("reload: %w", err))
+			continue
+		}
+		logAlert(fmt.Sprintf("reload: new Routine %q Ceiling: %d [min: %d, down: %d, up: %d, hold: %s]", l.r, l.c, l.minc, l.down, l.up, l.hold))
+		if name := f.Limiters[i].Profile; len(name) > 0 {
+			if p, ok := m.profiles[name]; ok {
+				l.prof = &profileRun{p: p, next: now}
+			} else {
+				logErr(fmt.Errorf("reload: limiter %q: profile %q not registered", cfg.Name, name))
+			}
+		}
+	}
+	m.runProfiles(now)
+}
+
+// reconfigure applies a changed configuration, logging each change.
+// Called by grmgr only.
+func (l *Limiter) reconfigure(cfg LimiterConfig, now time.Time) {
+
+	if cfg.Fast != l.fast {
+		logErr(fmt.Errorf("reload: %s cannot change fast from %t to %t", l.or, l.fast, cfg.Fast))
+	}
+	if Routine(cfg.Parent) != l.pname {
+		logAlert(fmt.Sprintf("reload: %s cannot change parent from %q to %q, parent unchanged", l.or, l.pname, cfg.Parent))
+	}
+	c := l.c
+	if cfg.Ceiling != l.maxc {
+		// a new ceiling takes effect immediately
+		c = cfg.Ceiling
+	}
+	if cfg.Min != l.minc || cfg.Ceiling != l.maxc {
+		logAlert(fmt.Sprintf("reload: %s bounds changed from [%d, %d] to [%d, %d]", l.or, l.minc, l.maxc, cfg.Min, cfg.Ceiling))
+		l.minc, l.maxc = cfg.Min, cfg.Ceiling
+	}
+	if c < l.minc {
+		c = l.minc
+	}
+	if c > l.maxc {
+		c = l.maxc
+	}
+	if c != l.c {
+		if c < l.c {
+			l.throttleDownActioned = now
+		} else {
+			l.throttleUpActioned = now
+		}
+		logAlert(fmt.Sprintf("reload: %s ceiling changed from %d to %d", l.or, l.c, c))
+		l.setC(c)
+	}
+	if cfg.Up != l.up || cfg.Down != l.down {
+		logAlert(fmt.Sprintf("reload: %s steps changed from [up: %d, down: %d] to [up: %d, down: %d]", l.or, l.up, l.down, cfg.Up, cfg.Down))
+		l.up, l.down = cfg.Up, cfg.Down
+	}
+	if cfg.Hold != l.hold {
+		logAlert(fmt.Sprintf("reload: %s hold changed from %s to %s", l.or, l.hold, cfg.Hold))
+		l.hold = cfg.Hold
+	}
+}
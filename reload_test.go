@@ -0,0 +1,69 @@
+package grmgr
+
+import (
+	"os"
+	"testing"
+	"time"
+)
+
+func TestReloadConfig(t *testing.T) {
+	path := writeConfig(t, "limiters.yaml", "limiters:\n  - {name: loader, ceiling: 2, min: 1, hold: 1h}\n")
+	m, _ := startManager(t, Config{"configfile": path, "reloadinterval": "10ms"})
+
+	l := m.Get("loader")
+	if l == nil {
+		t.Fatal("expected limiter loaded at startup")
+	}
+	if n := slots(l); n != 2 {
+		t.Fatalf("expected ceiling 2 got %d", n)
+	}
+
+	// a waiting routine is released when the ceiling rises
+	l.Control()
+	l.Control()
+	granted := make(chan struct{})
+	go func() {
+		l.Control()
+		close(granted)
+	}()
+
+	content := "limiters:\n  - {name: loader, ceiling: 4, min: 2, up: 2, hold: 1s}\n  - {name: writer, ceiling: 3}\n"
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	later := time.Now().Add(time.Minute)
+	os.Chtimes(path, later, later)
+
+	select {
+	case <-granted:
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected waiting routine released by reload")
+	}
+	for i := 0; i < 3; i++ {
+		l.Done()
+	}
+	if n := slots(l); n != 4 {
+		t.Errorf("expected ceiling 4 got %d", n)
+	}
+	if m.Get("writer") == nil {
+		t.Error("expected new limiter registered by reload")
+	}
+}
+
+func TestReconfigure(t *testing.T) {
+	m := NewManager()
+	l := newLimiter(m, LimiterConfig{Name: "r", Ceiling: 8, Min: 1, Up: 1, Down: 2, Hold: time.Minute})
+	m.register(l)
+	l.c = 3 // throttled down
+
+	// unchanged ceiling keeps the throttled value, within the new bounds
+	l.reconfigure(LimiterConfig{Name: "r", Ceiling: 8, Min: 4, Up: 2, Down: 3, Hold: time.Second}, time.Now())
+	if l.c != 4 || l.minc != 4 || l.up != 2 || l.down != 3 || l.hold != time.Second {
+		t.Errorf("unexpected limiter after reconfigure: c %d min %d up %d down %d hold %s", l.c, l.minc, l.up, l.down, l.hold)
+	}
+	// a new ceiling takes effect
+	l.reconfigure(LimiterConfig{Name: "r", Ceiling: 6, Min: 4, Up: 2, Down: 3, Hold: time.Second}, time.Now())
+	if l.c != 6 || l.maxc != 6 {
+		t.Errorf("expected ceiling 6 got %d [max: %d]", l.c, l.maxc)
+	}
+}
@@ -0,0 +1,209 @@
+package grmgr
+
+import (
+	"fmt"
+	"math"
+	"time"
+)
+
+// Observation is a Limiter's behaviour over the last scaling interval, presented to its Scaler.
+type Observation struct {
+	Limiter Routine
+	//
+	Ceiling Ceiling // current ceiling
+	Min     Ceiling // minimum ceiling
+	Max     Ceiling // maximum ceiling
+	//
+	Active    int // units in use at end of interval
+	Waiting   int // routines waiting at end of interval
+	Completed int // tasks completed during interval
+	// Latency estimates the mean time between Control() and Done() over the interval. See Scaler.
+	// Zero when no task completed.
+	Latency  time.Duration
+	Interval time.Duration
+}
+
+// Scaler decides a Limiter's ceiling from its observed behaviour. grmgr clamps the result to the
+// Limiter's minimum and maximum ceiling and applies it only when the Limiter's hold period has
+// expired since its last change. A Scaler is called from the grmgr goroutine only, but may keep
+// state, so an instance should not be shared between Limiters.
+//
+// Observation.Latency is not measured per task. It is derived from the mean units in use and the
+// completion rate over the interval (Little's law), so it is an approximation: a ControlN(n) task
+// counts n times in the units in use but once in the completions, inflating the estimate; tasks
+// that run through a pause, or span several intervals, are attributed to the interval they complete in.
+// Scalers should therefore respond to sustained trends rather than a single Observation.
+type Scaler interface {
+	Scale(o Observation) Ceiling
+}
+
+// AIMD is an additive increase, multiplicative decrease Scaler. While the Limiter is saturated and
+// latency is at or below Target the ceiling is increased by Increase; when latency exceeds Target
+// the ceiling is multiplied by Backoff.
+type AIMD struct {
+	Target   time.Duration
+	Increase int     // default 1
+	Backoff  float64 // default 0.9
+}
+
+func (a *AIMD) Scale(o Observation) Ceiling {
+
+	if o.Completed == 0 {
+		return o.Ceiling
+	}
+	if o.Latency > a.Target {
+		backoff := a.Backoff
+		if backoff <= 0 || backoff >= 1 {
+			backoff = 0.9
+		}
+		c := int(float64(o.Ceiling) * backoff)
+		if c == o.Ceiling {
+			c--
+		}
+		return c
+	}
+	if o.Waiting > 0 || o.Active >= o.Ceiling {
+		inc := a.Increase
+		if inc <= 0 {
+			inc = 1
+		}
+		return o.Ceiling + inc
+	}
+	return o.Ceiling
+}
+
+// Gradient is a Scaler that compares the observed latency with the lowest latency seen. When latency
+// rises above Tolerance times the lowest, the ceiling is reduced in proportion, otherwise it is
+// allowed to grow by a queue allowance of sqrt(ceiling). The new ceiling is smoothed.
+// Modelled on the gradient limit of Netflix's concurrency-limits.
+type Gradient struct {
+	Tolerance float64 // default 1.5
+	Smoothing float64 // weight given to the new estimate, default 0.2
+	//
+	minLatency time.Duration
+	estimate   float64
+}
+
+func (g *Gradient) Scale(o Observation) Ceiling {
+
+	if o.Completed == 0 || o.Latency <= 0 {
+		return o.Ceiling
+	}
+	tolerance, smoothing := g.Tolerance, g.Smoothing
+	if tolerance < 1 {
+		tolerance = 1.5
+	}
+	if smoothing <= 0 || smoothing > 1 {
+		smoothing = 0.2
+	}
+	if g.minLatency == 0 || o.Latency < g.minLatency {
+		g.minLatency = o.Latency
+	}
+	if g.estimate == 0 {
+		g.estimate = float64(o.Ceiling)
+	}
+
+	gradient := tolerance * float64(g.minLatency) / float64(o.Latency)
+	gradient = math.Max(0.5, math.Min(1.0, gradient))
+	c := float64(o.Ceiling)*gradient + math.Sqrt(float64(o.Ceiling))
+
+	g.estimate = (1-smoothing)*g.estimate + smoothing*c
+	return Ceiling(math.Round(g.estimate))
+}
+
+type scaleReq struct {
+	r   Routine
+	s   Scaler
+	err chan error
+}
+
+// AutoScale hands control of the Limiter's ceiling to s, which grmgr consults every scaling interval
+// (Config key "scaleinterval", default 1s). Pass nil to return to manual Up()/Down().
+// Latency is estimated by grmgr (see Scaler); for fast Limiters the units in use are sampled
+// at the end of each interval, so it is coarser still. Returns an error once grmgr is powered off
+// or the Limiter is unregistered.
+func (l *Limiter) AutoScale(s Scaler) error {
+	req := scaleReq{r: l.r, s: s, err: make(chan error, 1)}
+	select {
+	case l.m.scaleCh <- req:
+	case <-l.m.powerOffCh:
+		return &ControlError{Limiter: l.r, Err: ErrPoweredOff}
+	}
+	return <-req.err
+}
+
+// add changes the units in use, accumulating the time integral used to derive latency.
+// Called by grmgr only.
+func (l *Limiter) add(n int) {
+	now := time.Now()
+	l.area += float64(l.rCnt) * now.Sub(l.areaT).Seconds()
+	l.areaT = now
+	l.rCnt += n
+	if l.parent != nil && n != 0 {
+		// units are drawn from the parent's budget too
+		l.parent.add(n)
+	}
+}
+
+// observe returns the limiter's behaviour since the last observation and resets the counters.
+// Called by grmgr only.
+func (l *Limiter) observe(now time.Time, interval time.Duration) Observation {
+
+	o := Observation{Limiter: l.r, Ceiling: l.c, Min: l.minc, Max: l.maxc, Interval: interval}
+
+	if l.fast {
+		o.Active, o.Waiting = l.active(), int(l.fwait.Load())
+		o.Completed = int(l.fdone.Swap(0))
+		l.area = float64(o.Active) * interval.Seconds()
+	} else {
+		l.add(0)
+		o.Active, o.Waiting = l.rCnt, len(l.waitq)
+		o.Completed = l.completed
+	}
+	if o.Completed > 0 {
+		// Little's law: mean latency = mean units in use / completion rate
+		o.Latency = time.Duration(l.area / float64(o.Completed) * float64(time.Second))
+	}
+	l.completed, l.area = 0, 0
+
+	return o
+}
+
+// autoscale consults the Scaler of each Limiter.
+// Called by grmgr only.
+func (m *Manager) autoscale(now time.Time) {
+
+	for _, l := range m.rLimit {
+		if l.scaler == nil {
+			continue
+		}
+		o := l.observe(now, m.scaleInterval)
+		if l.paused {
+			// throughput while paused says nothing about the ceiling
+			continue
+		}
+		c := l.scaler.Scale(o)
+		if c < l.minc {
+			c = l.minc
+		}
+		if c > l.maxc {
+			c = l.maxc
+		}
+		if c == l.c {
+			continue
+		}
+		if now.Sub(l.throttleDownActioned) < l.hold || now.Sub(l.throttleUpActioned) < l.hold {
+			l.metrics.holds.Add(1)
+			logDebug(fmt.Sprintf("autoscale: %s holding at %d [proposed: %d]", l.or, l.c, c))
+			continue
+		}
+		if c < l.c {
+			l.throttleDownActioned = now
+			logAlert(fmt.Sprintf("autoscale: %s throttled down to %d [minimum: %d, latency: %s]", l.or, c, l.minc, o.Latency))
+		} else {
+			l.throttleUpActioned = now
+			logAlert(fmt.Sprintf("autoscale: %s throttled up to %d [maximum: %d, latency: %s]", l.or, c, l.maxc, o.Latency))
+		}
+		l.setC(c)
+	}
+}
@@ -0,0 +1,100 @@
+package grmgr
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestAIMD(t *testing.T) {
+	a := &AIMD{Target: 100 * time.Millisecond, Increase: 2, Backoff: 0.5}
+	o := Observation{Ceiling: 10, Min: 1, Max: 20, Completed: 5}
+
+	o.Latency, o.Active = 50*time.Millisecond, 10
+	if c := a.Scale(o); c != 12 {
+		t.Errorf("saturated under target: expected 12 got %d", c)
+	}
+	o.Active = 4
+	if c := a.Scale(o); c != 10 {
+		t.Errorf("idle under target: expected 10 got %d", c)
+	}
+	o.Latency = 200 * time.Millisecond
+	if c := a.Scale(o); c != 5 {
+		t.Errorf("over target: expected 5 got %d", c)
+	}
+	o.Completed = 0
+	if c := a.Scale(o); c != 10 {
+		t.Errorf("no completions: expected 10 got %d", c)
+	}
+}
+
+func TestGradient(t *testing.T) {
+	g := &Gradient{Smoothing: 1}
+	o := Observation{Ceiling: 16, Completed: 10, Latency: 10 * time.Millisecond}
+
+	if c := g.Scale(o); c != 20 {
+		t.Errorf("steady latency: expected 16+sqrt(16)=20 got %d", c)
+	}
+	o.Latency = 60 * time.Millisecond
+	if c := g.Scale(o); c != 12 {
+		t.Errorf("latency x6: expected 16*0.5+4=12 got %d", c)
+	}
+}
+
+type fixedScaler Ceiling
+
+func (f fixedScaler) Scale(o Observation) Ceiling {
+	return Ceiling(f)
+}
+
+func TestAutoscaleBoundsAndHold(t *testing.T) {
+	m := NewManager()
+	t0 := time.Now()
+	l := &Limiter{m: m, r: "as", or: "as", c: 5, maxc: 8, minc: 2, hold: time.Minute, areaT: t0,
+		throttleUpActioned: t0.Add(-time.Hour), throttleDownActioned: t0.Add(-time.Hour)}
+	m.rLimit[l.r] = l
+
+	l.scaler = fixedScaler(100)
+	m.autoscale(t0)
+	if l.c != 8 {
+		t.Fatalf("expected ceiling clamped to maximum 8 got %d", l.c)
+	}
+	l.scaler = fixedScaler(0)
+	m.autoscale(t0.Add(time.Second))
+	if l.c != 8 {
+		t.Fatalf("expected ceiling held at 8 got %d", l.c)
+	}
+	m.autoscale(t0.Add(2 * time.Minute))
+	if l.c != 2 {
+		t.Fatalf("expected ceiling clamped to minimum 2 got %d", l.c)
+	}
+}
+
+func TestAutoScale(t *testing.T) {
+	m, _ := startManager(t, Config{"scaleinterval": "10ms"})
+	l, _ := m.NewConfig("autoscale", 4, 1, 1, 1, "0s")
+
+	l.AutoScale(fixedScaler(2))
+	time.Sleep(100 * time.Millisecond)
+
+	if !l.TryControl() || !l.TryControl() {
+		t.Fatal("expected two slots")
+	}
+	if l.TryControl() {
+		t.Fatal("expected ceiling scaled down to 2")
+	}
+	l.Done()
+	l.Done()
+	l.AutoScale(nil)
+}
+
+func TestAutoScalePoweredOff(t *testing.T) {
+	m, cancel := startManager(t)
+	l := m.New("autoscale-off", 2)
+	cancel()
+	<-m.powerOffCh
+
+	if err := l.AutoScale(fixedScaler(1)); !errors.Is(err, ErrPoweredOff) {
+		t.Errorf("expected ErrPoweredOff got %v", err)
+	}
+}
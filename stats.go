@@ -0,0 +1,467 @@
+package grmgr
+
+import (
+	"context"
+	"crypto/rand"
+	"fmt"
+	"io"
+	"math"
+	"sync"
+	"time"
+)
+
+var (
+	// take a snapshot of rCnt slice every snapInterval - keep upto 2hrs worth of data
+	defaultSnapInterval = 2 * time.Second
+	// report to the sink every snapReportInterval
+	defaultSnapReportInterval = 10 * time.Second
+	// keep live averages over the following windows
+	defaultWindows = []time.Duration{10 * time.Second, 20 * time.Second, 40 * time.Second, time.Minute, 2 * time.Minute, 3 * time.Minute,
+		5 * time.Minute, 10 * time.Minute, 20 * time.Minute, 40 * time.Minute, time.Hour, 2 * time.Hour}
+)
+
+// Sample is a snapshot of a Limiter taken every snap interval.
+type Sample struct {
+	Time      time.Time     `json:"time"`
+	Active    int           `json:"active"`    // units in use
+	Waiting   int           `json:"waiting"`   // routines waiting for a slot
+	Ceiling   Ceiling       `json:"ceiling"`   // current ceiling
+	Completed int           `json:"completed"` // tasks completed since the previous sample
+	Wait      time.Duration `json:"wait"`      // mean Control() wait of slots granted since the previous sample
+}
+
+// Summary describes the values of a metric over a window.
+type Summary struct {
+	Mean float64 `json:"mean"`
+	Min  float64 `json:"min"`
+	Max  float64 `json:"max"`
+	P50  float64 `json:"p50"`
+	P95  float64 `json:"p95"`
+	P99  float64 `json:"p99"`
+}
+
+// WindowStats summarises a Limiter's samples over the most recent reporting window. A window is
+// only summarised once it is full, otherwise its summaries are zero.
+type WindowStats struct {
+	Window    time.Duration `json:"window"`
+	Column    string        `json:"column"`    // name of the window e.g. "s10", "m1", "h2"
+	Active    Summary       `json:"active"`    // units in use
+	Waiting   Summary       `json:"waiting"`   // routines waiting
+	Ceiling   Summary       `json:"ceiling"`   // ceiling
+	Completed Summary       `json:"completed"` // tasks completed per snap interval
+	Wait      Summary       `json:"wait"`      // mean Control() wait per snap interval, in seconds
+}
+
+// Values returns the window's statistics as named values, the mean units in use being named by
+// Column and the others by Column plus a suffix e.g. "m1_p95", "m1_waiting", "m1_wait_p95".
+func (w WindowStats) Values() (names []string, values []float64) {
+	add := func(suffix string, v float64) {
+		names = append(names, w.Column+suffix)
+		values = append(values, v)
+	}
+	add("", w.Active.Mean)
+	add("_min", w.Active.Min)
+	add("_max", w.Active.Max)
+	add("_p50", w.Active.P50)
+	add("_p95", w.Active.P95)
+	add("_p99", w.Active.P99)
+	add("_waiting", w.Waiting.Mean)
+	add("_waiting_max", w.Waiting.Max)
+	add("_ceiling", w.Ceiling.Mean)
+	add("_completed", w.Completed.Mean)
+	add("_wait", w.Wait.Mean)
+	add("_wait_p95", w.Wait.P95)
+	add("_wait_p99", w.Wait.P99)
+	return names, values
+}
+
+// summarize returns the summary of vs, in ascending order, given their sum.
+func summarize(vs []float64, sum float64) Summary {
+	if len(vs) == 0 {
+		return Summary{}
+	}
+	// nearest rank
+	rank := func(p float64) float64 {
+		i := int(math.Ceil(p*float64(len(vs)))) - 1
+		if i < 0 {
+			i = 0
+		}
+		return vs[i]
+	}
+	return Summary{Mean: sum / float64(len(vs)), Min: vs[0], Max: vs[len(vs)-1], P50: rank(0.5), P95: rank(0.95), P99: rank(0.99)}
+}
+
+// sample takes a snapshot of the limiter.
+// Called by grmgr only.
+func (l *Limiter) sample(t time.Time) Sample {
+
+	s := Sample{Time: t, Active: l.active(), Waiting: len(l.waitq), Ceiling: l.c}
+	if l.fast {
+		s.Waiting = int(l.fwait.Load())
+	}
+	completions, waits, waitSum := l.metrics.completions.Load(), l.metrics.waitCount(), l.metrics.waitSum.Load()
+	s.Completed = int(completions - l.sampled.completions)
+	if n := waits - l.sampled.waits; n > 0 {
+		s.Wait = time.Duration((waitSum - l.sampled.waitSum) / n)
+	}
+	l.sampled.completions, l.sampled.waits, l.sampled.waitSum = completions, waits, waitSum
+	return s
+}
+
+// StatsSink receives each Limiter's statistics every report interval: a summary of each reporting
+// window and the samples taken since the previous report. Write is called on the
+// grmgr goroutine. A sink opened by grmgr from a Config name is closed on shutdown.
+type StatsSink interface {
+	Write(runID string, limiter Routine, windows []WindowStats, samples []Sample) error
+}
+
+// stats takes a snapshot (Sample) of each limiter every snap interval, keeping enough history to cover
+// the longest window (see History), and, when a sink is configured, writes a summary of each window
+// to the StatsSink every report interval. No snapshots are taken without a sink, unless the Config
+// key "history" is true so History can read them.
+//
+// The sink is selected by the Config keys:
+//
+//	"statssink"  a StatsSink, or the name of a built-in sink: "csv", "jsonl" or "db"
+//	"statsfile"  file appended to by the "csv" and "jsonl" sinks
+//	"runid"      identifies the run in each record, a string or fmt.Stringer e.g. uuid.UID.
+//	             Defaults to a random UUID.
+//	"dbname", "table"  database and table of the "db" sink (withstats build only), which is
+//	             selected when either, or a "runid", is given without a "statssink"
+//
+// and the snapshot intervals by:
+//
+//	"snapinterval"    time between snapshots, default 2s
+//	"reportinterval"  time between reports, a multiple of the snap interval, default 10s
+//	"windows"         averaging windows, each a multiple of the snap interval, as a []time.Duration
+//	                  or []string e.g. []string{"10s", "1m", "1h"}. Default 10s to 2h. Each window
+//	                  is reported under a column named after its duration e.g. "s10", "m1", "h1".
+type stats struct {
+	//snapshot reporting
+	s         int
+	rsnap     time.Duration
+	reportOn  bool
+	runId     string
+	sink      StatsSink
+	sinkName  string
+	ownSink   bool // sink opened by grmgr
+	statsFile string
+	dbname    string
+	reptbl    string
+	//
+	snapInterval       time.Duration
+	snapReportInterval time.Duration
+	windows            []time.Duration
+	columns            []string // column name of each window
+	nsamples           []int    // snapshots in each window
+	//
+	history     map[string]*history // recent samples of each limiter
+	capacity    int                 // samples held per limiter
+	keepHistory bool                // take snapshots without a sink, for History
+	//
+	snapCh     chan time.Time
+	cancelSnap context.CancelFunc
+	wgSnap     sync.WaitGroup
+}
+
+func (s *stats) configure(k string, v interface{}) bool {
+
+	switch k {
+	case "runid":
+		switch id := v.(type) {
+		case string:
+			s.runId = id
+		case fmt.Stringer:
+			s.runId = id.String()
+		default:
+			logErr(fmt.Errorf("runid should be a string or fmt.Stringer e.g. uuid.UID"))
+		}
+		s.reportOn = true
+	case "statssink":
+		switch sk := v.(type) {
+		case StatsSink:
+			s.sink = sk
+		case string:
+			s.sinkName = sk
+		default:
+			logErr(fmt.Errorf("statssink should be a StatsSink or one of csv, jsonl, db"))
+		}
+		s.reportOn = true
+	case "statsfile":
+		s.statsFile, _ = v.(string)
+	case "history":
+		b, ok := v.(bool)
+		if !ok {
+			logErr(fmt.Errorf("history should be a bool: %v", v))
+			break
+		}
+		s.keepHistory = b
+	case "snapinterval", "reportinterval":
+		d, err := toDuration(v)
+		if err != nil || d <= 0 {
+			logErr(fmt.Errorf("%s should be a positive time.Duration or duration string: %v", k, v))
+			break
+		}
+		if k == "snapinterval" {
+			s.snapInterval = d
+		} else {
+			s.snapReportInterval = d
+		}
+	case "windows":
+		w, err := toDurations(v)
+		if err != nil {
+			logErr(fmt.Errorf("windows: %w", err))
+			break
+		}
+		s.windows = w
+	case "dbname":
+		s.reportOn = true
+		s.dbname, _ = v.(string)
+	case "table":
+		s.reportOn = true
+		s.reptbl, _ = v.(string)
+	default:
+		return false
+	}
+	return true
+}
+
+func (s *stats) validate() {
+
+	if err := s.validateIntervals(); err != nil {
+		logErr(err)
+		logAlert("Will use default snapshot intervals")
+		s.snapInterval, s.snapReportInterval, s.windows = 0, 0, nil
+		s.validateIntervals()
+	}
+	if !s.reportOn || s.sink != nil {
+		return
+	}
+	if len(s.sinkName) == 0 {
+		s.sinkName = "db"
+	}
+	var err error
+	switch s.sinkName {
+	case "csv", "jsonl":
+		if len(s.statsFile) == 0 {
+			err = fmt.Errorf("statssink %q requires a statsfile", s.sinkName)
+			break
+		}
+		if s.sinkName == "csv" {
+			s.sink, err = NewCSVSink(s.statsFile)
+		} else {
+			s.sink, err = NewJSONLSink(s.statsFile)
+		}
+	case "db":
+		if len(s.dbname) == 0 {
+			logAlert(`no database name specified in config. Will use "default"`)
+			s.dbname = "default"
+		}
+		if len(s.reptbl) == 0 {
+			logAlert(`no database name specified in config. Will use "runStats"`)
+			s.reptbl = "runStats"
+		}
+		s.sink, err = newDBSink(s.dbname, s.reptbl)
+	default:
+		err = fmt.Errorf("not a supported statssink %q", s.sinkName)
+	}
+	if err != nil {
+		logErr(err)
+		s.reportOn, s.sink = false, nil
+		return
+	}
+	s.ownSink = true
+}
+
+// validateIntervals applies defaults to the snapshot intervals and checks they are multiples of
+// the snap interval, deriving the column name and number of snapshots of each window.
+func (s *stats) validateIntervals() error {
+
+	if s.snapInterval == 0 {
+		s.snapInterval = defaultSnapInterval
+	}
+	if s.snapReportInterval == 0 {
+		s.snapReportInterval = defaultSnapReportInterval
+	}
+	if len(s.windows) == 0 {
+		s.windows = defaultWindows
+	}
+	if s.snapReportInterval%s.snapInterval != 0 {
+		return fmt.Errorf("reportinterval %s is not a multiple of snapinterval %s", s.snapReportInterval, s.snapInterval)
+	}
+	s.columns, s.nsamples = nil, nil
+	for i, w := range s.windows {
+		if w <= 0 || w%s.snapInterval != 0 {
+			return fmt.Errorf("window %s is not a multiple of snapinterval %s", w, s.snapInterval)
+		}
+		if i > 0 && w <= s.windows[i-1] {
+			return fmt.Errorf("windows must be in ascending order, %s follows %s", w, s.windows[i-1])
+		}
+		s.columns = append(s.columns, column(w))
+		s.nsamples = append(s.nsamples, int(w/s.snapInterval))
+	}
+	s.capacity = s.nsamples[len(s.nsamples)-1]
+	if n := int(s.snapReportInterval / s.snapInterval); n > s.capacity {
+		s.capacity = n
+	}
+	return nil
+}
+
+// column names a window by its duration in the largest whole unit e.g. "s10", "m1", "h2", "ms500".
+func column(w time.Duration) string {
+	switch {
+	case w%time.Hour == 0:
+		return fmt.Sprintf("h%d", w/time.Hour)
+	case w%time.Minute == 0:
+		return fmt.Sprintf("m%d", w/time.Minute)
+	case w%time.Second == 0:
+		return fmt.Sprintf("s%d", w/time.Second)
+	}
+	return fmt.Sprintf("ms%d", w/time.Millisecond)
+}
+
+// toDurations accepts a []time.Duration, or a []string or []interface{} of values accepted by toDuration.
+func toDurations(v interface{}) ([]time.Duration, error) {
+	var vs []interface{}
+	switch d := v.(type) {
+	case []time.Duration:
+		return append([]time.Duration(nil), d...), nil
+	case []string:
+		for _, s := range d {
+			vs = append(vs, s)
+		}
+	case []interface{}:
+		vs = d
+	default:
+		return nil, fmt.Errorf("not a list of durations: %T", v)
+	}
+	ds := make([]time.Duration, len(vs))
+	for i, v := range vs {
+		d, err := toDuration(v)
+		if err != nil {
+			return nil, err
+		}
+		ds[i] = d
+	}
+	return ds, nil
+}
+
+// start the report-snapshot goroutine, if reporting is on or history is kept.
+func (s *stats) start() {
+
+	s.history = make(map[string]*history)
+
+	if !s.reportOn && !s.keepHistory {
+		return
+	}
+	if len(s.nsamples) == 0 {
+		s.validateIntervals()
+	}
+	if s.reportOn && len(s.runId) == 0 {
+		s.runId = newRunID()
+		logAlert(fmt.Sprintf("no runid specified in config. Will use %s", s.runId))
+	}
+
+	s.snapCh = make(chan time.Time)
+	ctxSnap, cancelSnap := context.WithCancel(context.Background())
+	s.cancelSnap = cancelSnap
+	s.wgSnap.Add(1)
+
+	go func() {
+		defer s.wgSnap.Done()
+		logAlert("Report-snapshot Powering up...")
+		for {
+			select {
+			case t := <-time.After(s.snapInterval):
+				select {
+				case s.snapCh <- t:
+				case <-ctxSnap.Done():
+					logAlert("Report-snapshot Shutdown.")
+					return
+				}
+			case <-ctxSnap.Done():
+				logAlert("Report-snapshot Shutdown.")
+				return
+			}
+		}
+
+	}()
+}
+
+// snapC returns the snapshot channel
+func (s *stats) snapC() <-chan time.Time {
+	return s.snapCh
+}
+
+func (s *stats) snap(t time.Time, rLimit rLimiterMap) {
+
+	s.s++
+	s.rsnap += s.snapInterval
+	// add a sample of each limiter to its history
+	for k, v := range rLimit {
+		h, ok := s.history[k]
+		if !ok {
+			h = newHistory(s.capacity, len(s.windows))
+			s.history[k] = h
+		}
+		h.add(v.sample(t), s.nsamples)
+	}
+	// report every snapReportInterval (default: 10s)
+	if s.rsnap >= s.snapReportInterval {
+		if s.reportOn {
+			s.report()
+			logDebug("gr dump report to sink completed...")
+		}
+		s.rsnap, s.s = 0, 0
+	}
+}
+
+func (s *stats) unregister(r Routine) {
+	delete(s.history, r)
+}
+
+func (s *stats) stop() {
+
+	if s.cancelSnap == nil {
+		return
+	}
+	s.cancelSnap()
+	logAlert("Waiting for internal snap service to shutdown...")
+	s.wgSnap.Wait()
+	logAlert("Internal snap service shutdown")
+	if c, ok := s.sink.(io.Closer); ok && s.ownSink {
+		if err := c.Close(); err != nil {
+			logErr(err)
+		}
+	}
+}
+
+// report writes a summary of each window for each grmgr limiter (throttler) to the sink,
+// together with the samples taken since the previous report.
+func (s *stats) report() {
+
+	for k, h := range s.history {
+		if h.len() < s.s {
+			// not enough snapshots taken for limiter k - ignore for this report
+			continue
+		}
+		ws := make([]WindowStats, len(s.windows))
+		for i, w := range s.windows {
+			ws[i] = h.window(i, w, s.columns[i], s.nsamples[i])
+		}
+		if err := s.sink.Write(s.runId, Routine(k), ws, h.last(s.s)); err != nil {
+			logErr(err)
+		}
+	}
+}
+
+// newRunID returns a random (version 4) UUID.
+func newRunID() string {
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return time.Now().UTC().Format("20060102T150405.000000000")
+	}
+	b[6] = (b[6] & 0x0f) | 0x40
+	b[8] = (b[8] & 0x3f) | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:])
+}
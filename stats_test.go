@@ -0,0 +1,155 @@
+package grmgr
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// report runs a report interval's worth of snapshots of l at n units in use.
+func report(s *stats, l *Limiter, n int) {
+	l.rCnt = n
+	for i := 0; i < int(s.snapReportInterval/s.snapInterval); i++ {
+		s.snap(l.areaT, rLimiterMap{l.r: l})
+	}
+}
+
+func TestStatsSinks(t *testing.T) {
+	dir := t.TempDir()
+	mem := NewMemorySink()
+	l := &Limiter{r: "loader"}
+
+	for _, cfg := range []Config{
+		{"statssink": mem, "runid": "run-1"},
+		{"statssink": "csv", "statsfile": filepath.Join(dir, "stats.csv"), "runid": "run-1"},
+		{"statssink": "jsonl", "statsfile": filepath.Join(dir, "stats.jsonl"), "runid": "run-1"},
+	} {
+		m := NewManager(cfg)
+		if m.stats.sink == nil {
+			t.Fatalf("expected sink for %v", cfg)
+		}
+		m.stats.start()
+		report(&m.stats, l, 4)
+		report(&m.stats, l, 2)
+		m.stats.stop()
+	}
+
+	recs := mem.Records()
+	if len(recs) != 2 || recs[0].RunID != "run-1" || recs[0].Limiter != "loader" {
+		t.Fatalf("unexpected records %+v", recs)
+	}
+	if w := recs[1].Windows; w[0].Column != "s10" || w[0].Active.Mean != 2 || w[1].Column != "s20" || w[1].Active.Mean != 3 {
+		t.Errorf("expected s10 average 2 and s20 average 3 got %+v", w[:2])
+	}
+	if s := recs[1].Samples; len(s) != 5 || s[0].Active != 2 {
+		t.Errorf("expected 5 samples of 2 got %v", s)
+	}
+
+	b, err := os.ReadFile(filepath.Join(dir, "stats.csv"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(b)), "\n")
+	if len(lines) != 3 || !strings.HasPrefix(lines[0], "time,run,limiter,s10,s10_min,s10_max,") || !strings.Contains(lines[2], ",run-1,loader,2,2,2,") {
+		t.Errorf("unexpected csv\n%s", b)
+	}
+
+	f, err := os.Open(filepath.Join(dir, "stats.jsonl"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	var n int
+	for sc := bufio.NewScanner(f); sc.Scan(); n++ {
+		var rec StatsRecord
+		if err := json.Unmarshal(sc.Bytes(), &rec); err != nil || rec.Limiter != "loader" {
+			t.Errorf("unexpected jsonl record %s: %v", sc.Text(), err)
+		}
+	}
+	if n != 2 {
+		t.Errorf("expected 2 jsonl records got %d", n)
+	}
+}
+
+func TestStatsSinkConfig(t *testing.T) {
+	if m := NewManager(Config{"statssink": "csv"}); m.stats.reportOn {
+		t.Error("expected reporting off for csv sink without statsfile")
+	}
+	if m := NewManager(Config{"statssink": "xml"}); m.stats.reportOn {
+		t.Error("expected reporting off for unknown sink")
+	}
+	if m := NewManager(); m.stats.reportOn {
+		t.Error("expected reporting off by default")
+	}
+	// a runid alone reports to the "db" sink, as before sinks were configurable
+	if m := NewManager(Config{"runid": "run-1"}); m.stats.sinkName != "db" {
+		t.Errorf("expected db sink for runid got %q", m.stats.sinkName)
+	}
+}
+
+func TestStatsIntervals(t *testing.T) {
+	mem := NewMemorySink()
+	m := NewManager(Config{"statssink": mem, "snapinterval": "500ms", "reportinterval": "1s", "windows": []string{"1s", "1500ms", "1m"}})
+	if cols := strings.Join(m.stats.columns, ","); cols != "s1,ms1500,m1" {
+		t.Fatalf("unexpected columns %s", cols)
+	}
+	l := &Limiter{r: "loader"}
+	m.stats.start()
+	report(&m.stats, l, 2)
+	report(&m.stats, l, 4)
+	m.stats.stop()
+
+	recs := mem.Records()
+	if len(recs) != 2 || len(recs[1].Samples) != 2 {
+		t.Fatalf("expected 2 reports of 2 samples got %+v", recs)
+	}
+	if w := recs[1].Windows; w[0].Active.Mean != 4 || w[1].Active.Mean != 10.0/3 || w[2].Active.Mean != 0 {
+		t.Errorf("unexpected averages %+v", w)
+	}
+
+	for _, cfg := range []Config{
+		{"snapinterval": "3s"},
+		{"windows": []string{"1m", "30s"}},
+		{"windows": []string{"3s"}},
+	} {
+		m := NewManager(cfg)
+		if m.stats.snapInterval != defaultSnapInterval || m.stats.snapReportInterval != defaultSnapReportInterval || len(m.stats.windows) != len(defaultWindows) {
+			t.Errorf("expected defaults for invalid config %v", cfg)
+		}
+	}
+}
+
+func TestSummarize(t *testing.T) {
+	vs := make([]float64, 100)
+	for i := range vs {
+		vs[i] = float64(i + 1)
+	}
+	s := summarize(vs, 5050)
+	if s.Mean != 50.5 || s.Min != 1 || s.Max != 100 || s.P50 != 50 || s.P95 != 95 || s.P99 != 99 {
+		t.Errorf("unexpected summary %+v", s)
+	}
+	if s := summarize(nil, 0); s != (Summary{}) {
+		t.Errorf("expected zero summary got %+v", s)
+	}
+}
+
+func TestSample(t *testing.T) {
+	l := &Limiter{r: "loader", c: 4, rCnt: 3, waitq: waitQueue{newWaiter(1, 0)}}
+	l.metrics.completions.Add(5)
+	l.metrics.observeWait(10 * time.Millisecond)
+	l.metrics.observeWait(30 * time.Millisecond)
+
+	s := l.sample(time.Now())
+	if s.Active != 3 || s.Waiting != 1 || s.Ceiling != 4 || s.Completed != 5 || s.Wait != 20*time.Millisecond {
+		t.Errorf("unexpected sample %+v", s)
+	}
+	// completions and waits are per sample
+	l.metrics.completions.Add(1)
+	if s := l.sample(time.Now()); s.Completed != 1 || s.Wait != 0 {
+		t.Errorf("unexpected second sample %+v", s)
+	}
+}
@@ -0,0 +1,159 @@
+package grmgr
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+)
+
+// RunStats is a Limiter's statistics as last reported in a run, named as by WindowStats.Values
+// e.g. "s10", "m1_p95", "h2_wait".
+type RunStats struct {
+	RunID   string
+	Limiter Routine
+	Names   []string
+	Values  []float64
+}
+
+// Value returns the named statistic.
+func (r RunStats) Value(name string) (float64, bool) {
+	for i, n := range r.Names {
+		if n == name {
+			return r.Values[i], true
+		}
+	}
+	return 0, false
+}
+
+// StatsReader reads back the statistics written by a StatsSink.
+type StatsReader interface {
+	// Runs returns the ids of the runs recorded.
+	Runs() ([]string, error)
+	// Load returns each Limiter's statistics for a run, ordered by Limiter.
+	Load(runID string) ([]RunStats, error)
+}
+
+// StatsDiff compares a statistic of a Limiter between two runs.
+type StatsDiff struct {
+	Limiter Routine
+	Name    string
+	A, B    float64
+}
+
+// Delta returns the change from run A to run B.
+func (d StatsDiff) Delta() float64 {
+	return d.B - d.A
+}
+
+// Diff compares the statistics of each Limiter recorded in both runs a and b. Along with the
+// statistics themselves each window's utilisation, its mean units in use over its mean ceiling,
+// is compared under the window's column plus "_util" e.g. "m1_util". Limiters in only one run are ignored.
+func Diff(a, b []RunStats) []StatsDiff {
+
+	bs := make(map[Routine]RunStats, len(b))
+	for _, r := range b {
+		bs[r.Limiter] = r
+	}
+	var d []StatsDiff
+	for _, ra := range a {
+		rb, ok := bs[ra.Limiter]
+		if !ok {
+			continue
+		}
+		for i, n := range ra.Names {
+			vb, ok := rb.Value(n)
+			if !ok {
+				continue
+			}
+			d = append(d, StatsDiff{Limiter: ra.Limiter, Name: n, A: ra.Values[i], B: vb})
+			ua, oka := utilisation(ra, n)
+			ub, okb := utilisation(rb, n)
+			if oka && okb {
+				d = append(d, StatsDiff{Limiter: ra.Limiter, Name: n + "_util", A: ua, B: ub})
+			}
+		}
+	}
+	return d
+}
+
+// utilisation returns the mean units in use over the mean ceiling of the window named column,
+// if r records the window's ceiling and the window was full.
+func utilisation(r RunStats, column string) (float64, bool) {
+	c, ok := r.Value(column + "_ceiling")
+	if !ok || c == 0 {
+		return 0, false
+	}
+	v, _ := r.Value(column)
+	return v / c, true
+}
+
+// JSONLReader reads the statistics written by a JSONLSink.
+type JSONLReader struct {
+	path string
+}
+
+// NewJSONLReader returns a reader of the JSON lines file at path.
+func NewJSONLReader(path string) *JSONLReader {
+	return &JSONLReader{path: path}
+}
+
+// read calls f with each record in the file.
+func (j *JSONLReader) read(f func(r StatsRecord)) error {
+	fh, err := os.Open(j.path)
+	if err != nil {
+		return err
+	}
+	defer fh.Close()
+	sc := bufio.NewScanner(fh)
+	sc.Buffer(nil, 16<<20)
+	for ln := 1; sc.Scan(); ln++ {
+		var r StatsRecord
+		if err := json.Unmarshal(sc.Bytes(), &r); err != nil {
+			return fmt.Errorf("%s:%d: %w", j.path, ln, err)
+		}
+		f(r)
+	}
+	return sc.Err()
+}
+
+// Runs returns the runs in the order they were first recorded.
+func (j *JSONLReader) Runs() ([]string, error) {
+	var runs []string
+	seen := make(map[string]bool)
+	err := j.read(func(r StatsRecord) {
+		if !seen[r.RunID] {
+			seen[r.RunID] = true
+			runs = append(runs, r.RunID)
+		}
+	})
+	return runs, err
+}
+
+// Load returns the statistics of each Limiter's last record in the run.
+func (j *JSONLReader) Load(runID string) ([]RunStats, error) {
+	last := make(map[Routine]StatsRecord)
+	err := j.read(func(r StatsRecord) {
+		if r.RunID == runID {
+			last[r.Limiter] = r
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(last) == 0 {
+		return nil, fmt.Errorf("run %q not found", runID)
+	}
+	rs := make([]RunStats, 0, len(last))
+	for l, r := range last {
+		s := RunStats{RunID: runID, Limiter: l}
+		for _, w := range r.Windows {
+			n, v := w.Values()
+			s.Names, s.Values = append(s.Names, n...), append(s.Values, v...)
+		}
+		rs = append(rs, s)
+	}
+	sort.Slice(rs, func(i, k int) bool { return rs[i].Limiter < rs[k].Limiter })
+	return rs, nil
+}
@@ -0,0 +1,61 @@
+package grmgr
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestJSONLReader(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "stats.jsonl")
+	sink, err := NewJSONLSink(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	write := func(run string, l Routine, active, ceiling float64) {
+		ws := []WindowStats{{Window: time.Minute, Column: "m1", Active: Summary{Mean: active}, Ceiling: Summary{Mean: ceiling}}}
+		if err := sink.Write(run, l, ws, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("run-1", "loader", 2, 8)
+	write("run-1", "writer", 1, 2)
+	write("run-1", "loader", 4, 8) // last record of the run is loaded
+	write("run-2", "loader", 6, 8)
+	write("run-2", "reader", 3, 4)
+	sink.Close()
+
+	r := NewJSONLReader(path)
+	runs, err := r.Runs()
+	if err != nil || len(runs) != 2 || runs[0] != "run-1" || runs[1] != "run-2" {
+		t.Fatalf("expected runs run-1, run-2 got %v %v", runs, err)
+	}
+	a, err := r.Load("run-1")
+	if err != nil || len(a) != 2 || a[0].Limiter != "loader" || a[1].Limiter != "writer" {
+		t.Fatalf("unexpected run-1 stats %+v %v", a, err)
+	}
+	if v, ok := a[0].Value("m1"); !ok || v != 4 {
+		t.Errorf("expected loader m1 of 4 got %v", v)
+	}
+	b, _ := r.Load("run-2")
+	if _, err := r.Load("run-3"); err == nil {
+		t.Error("expected error loading unknown run")
+	}
+
+	d := make(map[string]StatsDiff)
+	for _, s := range Diff(a, b) {
+		if s.Limiter != "loader" {
+			t.Fatalf("expected only loader, in both runs, compared got %+v", s)
+		}
+		d[s.Name] = s
+	}
+	if s := d["m1"]; s.A != 4 || s.B != 6 || s.Delta() != 2 {
+		t.Errorf("unexpected m1 diff %+v", s)
+	}
+	if s := d["m1_util"]; s.A != 0.5 || s.B != 0.75 {
+		t.Errorf("unexpected utilisation diff %+v", s)
+	}
+	if _, ok := d["m1_min_util"]; ok {
+		t.Error("expected utilisation of windows only")
+	}
+}
@@ -0,0 +1,128 @@
+package grmgr
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// StatsRecord is one Limiter's statistics for a report interval.
+type StatsRecord struct {
+	Time    time.Time     `json:"time"`
+	RunID   string        `json:"run"`
+	Limiter Routine       `json:"limiter"`
+	Windows []WindowStats `json:"windows"`
+	Samples []Sample      `json:"samples"`
+}
+
+// CSVSink appends a row per Limiter per report to a CSV file: time, run, limiter, a column for
+// each of the reporting windows' values (see WindowStats.Values) and the units in use of the
+// report's samples separated by spaces.
+type CSVSink struct {
+	f      *os.File
+	w      *csv.Writer
+	header bool
+}
+
+// NewCSVSink opens, or creates, the CSV file at path for appending.
+func NewCSVSink(path string) (*CSVSink, error) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	fi, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	return &CSVSink{f: f, w: csv.NewWriter(f), header: fi.Size() > 0}, nil
+}
+
+func (c *CSVSink) Write(runID string, limiter Routine, windows []WindowStats, samples []Sample) error {
+
+	if !c.header {
+		hdr := []string{"time", "run", "limiter"}
+		for _, w := range windows {
+			names, _ := w.Values()
+			hdr = append(hdr, names...)
+		}
+		c.w.Write(append(hdr, "samples"))
+		c.header = true
+	}
+	row := []string{time.Now().UTC().Format(time.RFC3339), runID, string(limiter)}
+	for _, w := range windows {
+		_, values := w.Values()
+		for _, v := range values {
+			row = append(row, strconv.FormatFloat(v, 'f', -1, 64))
+		}
+	}
+	smp := make([]string, len(samples))
+	for i, v := range samples {
+		smp[i] = strconv.Itoa(v.Active)
+	}
+	c.w.Write(append(row, strings.Join(smp, " ")))
+	c.w.Flush()
+	return c.w.Error()
+}
+
+func (c *CSVSink) Close() error {
+	return c.f.Close()
+}
+
+// JSONLSink appends a StatsRecord per Limiter per report to a file as JSON lines.
+type JSONLSink struct {
+	f   *os.File
+	enc *json.Encoder
+}
+
+// NewJSONLSink opens, or creates, the JSON lines file at path for appending.
+func NewJSONLSink(path string) (*JSONLSink, error) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	return &JSONLSink{f: f, enc: json.NewEncoder(f)}, nil
+}
+
+func (j *JSONLSink) Write(runID string, limiter Routine, windows []WindowStats, samples []Sample) error {
+	return j.enc.Encode(StatsRecord{Time: time.Now().UTC(), RunID: runID, Limiter: limiter, Windows: windows, Samples: samples})
+}
+
+func (j *JSONLSink) Close() error {
+	return j.f.Close()
+}
+
+// MemorySink keeps every StatsRecord in memory, e.g. for tests.
+type MemorySink struct {
+	sync.Mutex
+	records []StatsRecord
+}
+
+// NewMemorySink returns an empty MemorySink.
+func NewMemorySink() *MemorySink {
+	return &MemorySink{}
+}
+
+func (m *MemorySink) Write(runID string, limiter Routine, windows []WindowStats, samples []Sample) error {
+	m.Lock()
+	defer m.Unlock()
+	m.records = append(m.records, StatsRecord{
+		Time:    time.Now(),
+		RunID:   runID,
+		Limiter: limiter,
+		Windows: append([]WindowStats(nil), windows...),
+		Samples: append([]Sample(nil), samples...),
+	})
+	return nil
+}
+
+// Records returns a copy of the records written so far.
+func (m *MemorySink) Records() []StatsRecord {
+	m.Lock()
+	defer m.Unlock()
+	return append([]StatsRecord(nil), m.records...)
+}
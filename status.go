@@ -0,0 +1,111 @@
+package grmgr
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// LimiterStatus is a snapshot of a Limiter's state.
+type LimiterStatus struct {
+	Name          Routine       `json:"name"`     // registered (unique) name
+	Original      Routine       `json:"original"` // name requested at registration
+	Ceiling       Ceiling       `json:"ceiling"`
+	Max           Ceiling       `json:"max"`
+	Min           Ceiling       `json:"min"`
+	Active        int           `json:"active"`  // units in use
+	Waiting       int           `json:"waiting"` // routines waiting for a slot
+	Up            int           `json:"up"`
+	Down          int           `json:"down"`
+	Hold          time.Duration `json:"hold"`
+	Fast          bool          `json:"fast"`
+	Paused        bool          `json:"paused"`
+	Parent        Routine       `json:"parent,omitempty"` // Limiter whose budget this one shares
+	Stopped       bool          `json:"stopped"`          // no longer admitting tasks, see Manager.Stop
+	AutoScale     bool          `json:"autoscale"`
+	Profile       string        `json:"profile,omitempty"` // scaling profile in progress
+	ThrottledDown time.Time     `json:"throttledDown"`     // time of last decrease in ceiling
+	ThrottledUp   time.Time     `json:"throttledUp"`       // time of last increase in ceiling
+}
+
+// status returns the limiter's current state.
+// Called by grmgr only.
+func (l *Limiter) status() LimiterStatus {
+
+	s := LimiterStatus{
+		Name:          l.r,
+		Original:      l.or,
+		Ceiling:       l.c,
+		Max:           l.maxc,
+		Min:           l.minc,
+		Active:        l.active(),
+		Waiting:       len(l.waitq),
+		Up:            l.up,
+		Down:          l.down,
+		Hold:          l.hold,
+		Fast:          l.fast,
+		Paused:        l.paused,
+		Stopped:       l.m.draining,
+		AutoScale:     l.scaler != nil,
+		ThrottledDown: l.throttleDownActioned,
+		ThrottledUp:   l.throttleUpActioned,
+	}
+	if l.fast {
+		s.Waiting = int(l.fwait.Load())
+	}
+	if l.prof != nil {
+		s.Profile = l.prof.p.Name
+	}
+	if l.parent != nil {
+		s.Parent = l.parent.r
+	}
+	return s
+}
+
+// Status returns the state of every Limiter registered with m, ordered by name.
+// Returns nil once m is powered off.
+func (m *Manager) Status() []LimiterStatus {
+	resp := make(chan []LimiterStatus, 1)
+	select {
+	case m.statusCh <- resp:
+	case <-m.powerOffCh:
+		return nil
+	}
+	return <-resp
+}
+
+// status returns the state of all limiters.
+// Called by grmgr only.
+func (m *Manager) status() []LimiterStatus {
+	st := make([]LimiterStatus, 0, len(m.rLimit))
+	for _, l := range m.rLimit {
+		st = append(st, l.status())
+	}
+	sort.Slice(st, func(i, j int) bool { return st[i].Name < st[j].Name })
+	return st
+}
+
+// state names a Limiter's admission state.
+func (s LimiterStatus) state() string {
+	switch {
+	case s.Stopped:
+		return "stopped"
+	case s.Paused:
+		return "paused"
+	}
+	return "running"
+}
+
+// statusTable formats st as a table with a row per Limiter.
+func statusTable(st []LimiterStatus) string {
+	var b strings.Builder
+	tw := tabwriter.NewWriter(&b, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "LIMITER\tDOP\tMIN\tMAX\tACTIVE\tWAITING\tSTATE")
+	for _, s := range st {
+		fmt.Fprintf(tw, "%s\t%d\t%d\t%d\t%d\t%d\t%s\n", s.Name, s.Ceiling, s.Min, s.Max, s.Active, s.Waiting, s.state())
+	}
+	tw.Flush()
+	return b.String()
+}
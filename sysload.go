@@ -0,0 +1,287 @@
+package grmgr
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// LoadWatch configures WatchSystemLoad. Each threshold enables a host signal; a zero threshold
+// disables it. When any enabled signal exceeds its threshold all Limiters are throttled down.
+// When every enabled signal is below Recover times its threshold, Limiters that were throttled
+// down are throttled up again. Throttling is subject to each Limiter's hold period.
+type LoadWatch struct {
+	Interval   time.Duration // sample interval, default 5s
+	ProcRoot   string        // default "/proc"
+	CgroupRoot string        // cgroup v2 mount, default "/sys/fs/cgroup"
+	//
+	LoadPerCPU     float64 // 1 minute load average (/proc/loadavg) divided by number of CPUs
+	CPUPressure    float64 // PSI cpu "some avg10" (%)
+	MemoryPressure float64 // PSI memory "some avg10" (%)
+	IOPressure     float64 // PSI io "some avg10" (%)
+	CPUThrottled   float64 // cgroup cpu.stat periods throttled during interval (%)
+	//
+	Recover float64 // fraction of thresholds, default 0.8
+}
+
+// WatchSystemLoad throttles the Limiters of the default grmgr service on host load. See Manager.WatchSystemLoad.
+func WatchSystemLoad(ctx context.Context, w LoadWatch) error {
+	return Default().WatchSystemLoad(ctx, w)
+}
+
+// WatchSystemLoad samples the host signals configured in w every interval, throttling all Limiters
+// down while the host is overloaded and back up once it recovers. It runs until ctx is cancelled or
+// grmgr is powered off, e.g.
+//
+//	go m.WatchSystemLoad(ctx, grmgr.LoadWatch{CPUPressure: 40, MemoryPressure: 20})
+func (m *Manager) WatchSystemLoad(ctx context.Context, w LoadWatch) error {
+
+	lw, err := newLoadWatcher(w)
+	if err != nil {
+		return err
+	}
+	// first sample establishes the cgroup baseline
+	if _, _, err := lw.check(); err != nil {
+		return err
+	}
+	logAlert(fmt.Sprintf("Watching system load every %s", lw.Interval))
+
+	tick := time.NewTicker(lw.Interval)
+	defer tick.Stop()
+	lowered := make(map[Routine]int) // throttle downs not yet reversed, by Limiter
+
+	for {
+		select {
+		case <-tick.C:
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-m.powerOffCh:
+			return nil
+		}
+		overload, recovered, err := lw.check()
+		if err != nil {
+			logErr(err)
+			continue
+		}
+		switch {
+		case overload:
+			applied, err := m.throttleApplied(ctx, m.throttleDownCh, Routine("__all"))
+			if err != nil {
+				return watchErr(err)
+			}
+			for _, r := range applied {
+				lowered[r]++
+			}
+		case recovered:
+			// reverse only the throttle downs that were applied
+			for r := range lowered {
+				applied, err := m.throttleApplied(ctx, m.throttleUpCh, r)
+				if err != nil {
+					return watchErr(err)
+				}
+				if len(applied) > 0 {
+					if lowered[r]--; lowered[r] == 0 {
+						delete(lowered, r)
+					}
+				}
+			}
+		}
+	}
+}
+
+// throttleApplied sends a throttle request to grmgr and returns the Limiters it was applied to.
+func (m *Manager) throttleApplied(ctx context.Context, ch chan throttleReq, r Routine) ([]Routine, error) {
+
+	req := throttleReq{r: r, applied: make(chan []Routine, 1)}
+	select {
+	case ch <- req:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case <-m.powerOffCh:
+		return nil, ErrPoweredOff
+	}
+	return <-req.applied, nil
+}
+
+// watchErr is the WatchSystemLoad return value for err. It stops without error once grmgr is powered off.
+func watchErr(err error) error {
+	if err == ErrPoweredOff {
+		return nil
+	}
+	return err
+}
+
+// loadWatcher reads the host signals.
+type loadWatcher struct {
+	LoadWatch
+	ncpu float64
+	// previous cgroup cpu.stat reading
+	periods, throttled int64
+}
+
+func newLoadWatcher(w LoadWatch) (*loadWatcher, error) {
+
+	if w.LoadPerCPU <= 0 && w.CPUPressure <= 0 && w.MemoryPressure <= 0 && w.IOPressure <= 0 && w.CPUThrottled <= 0 {
+		return nil, fmt.Errorf("WatchSystemLoad: no load thresholds configured")
+	}
+	if w.Interval <= 0 {
+		w.Interval = 5 * time.Second
+	}
+	if len(w.ProcRoot) == 0 {
+		w.ProcRoot = "/proc"
+	}
+	if len(w.CgroupRoot) == 0 {
+		w.CgroupRoot = "/sys/fs/cgroup"
+	}
+	if w.Recover <= 0 || w.Recover > 1 {
+		w.Recover = 0.8
+	}
+	return &loadWatcher{LoadWatch: w, ncpu: float64(runtime.NumCPU())}, nil
+}
+
+// check reports whether any signal is over its threshold and whether all are below their recovery level.
+func (lw *loadWatcher) check() (overload bool, recovered bool, err error) {
+
+	recovered = true
+	signal := func(name string, v, threshold float64) {
+		if v > threshold {
+			logAlert(fmt.Sprintf("system load: %s %.2f exceeds %.2f", name, v, threshold))
+			overload = true
+		}
+		if v >= threshold*lw.Recover {
+			recovered = false
+		}
+	}
+
+	if lw.LoadPerCPU > 0 {
+		v, err := lw.loadAvg()
+		if err != nil {
+			return false, false, err
+		}
+		signal("load per cpu", v/lw.ncpu, lw.LoadPerCPU)
+	}
+	for _, p := range []struct {
+		res       string
+		threshold float64
+	}{{"cpu", lw.CPUPressure}, {"memory", lw.MemoryPressure}, {"io", lw.IOPressure}} {
+		if p.threshold <= 0 {
+			continue
+		}
+		v, err := lw.pressure(p.res)
+		if err != nil {
+			return false, false, err
+		}
+		signal(p.res+" pressure", v, p.threshold)
+	}
+	if lw.CPUThrottled > 0 {
+		v, err := lw.cpuThrottled()
+		if err != nil {
+			return false, false, err
+		}
+		signal("cpu throttled", v, lw.CPUThrottled)
+	}
+	return overload, recovered, nil
+}
+
+// loadAvg returns the 1 minute load average.
+func (lw *loadWatcher) loadAvg() (float64, error) {
+
+	b, err := os.ReadFile(filepath.Join(lw.ProcRoot, "loadavg"))
+	if err != nil {
+		return 0, err
+	}
+	f := strings.Fields(string(b))
+	if len(f) == 0 {
+		return 0, fmt.Errorf("loadavg: unexpected format %q", b)
+	}
+	return strconv.ParseFloat(f[0], 64)
+}
+
+// pressure returns the PSI "some avg10" value for resource res.
+func (lw *loadWatcher) pressure(res string) (float64, error) {
+
+	fn := filepath.Join(lw.ProcRoot, "pressure", res)
+	kv, err := readFields(fn, "some")
+	if err != nil {
+		return 0, err
+	}
+	v, ok := kv["avg10"]
+	if !ok {
+		return 0, fmt.Errorf("%s: no some avg10 entry", fn)
+	}
+	return strconv.ParseFloat(v, 64)
+}
+
+// cpuThrottled returns the percentage of cgroup cpu periods throttled since the previous call.
+func (lw *loadWatcher) cpuThrottled() (float64, error) {
+
+	fn := filepath.Join(lw.CgroupRoot, "cpu.stat")
+	f, err := os.Open(fn)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+
+	var periods, throttled int64
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		fs := strings.Fields(sc.Text())
+		if len(fs) != 2 {
+			continue
+		}
+		switch fs[0] {
+		case "nr_periods":
+			periods, err = strconv.ParseInt(fs[1], 10, 64)
+		case "nr_throttled":
+			throttled, err = strconv.ParseInt(fs[1], 10, 64)
+		}
+		if err != nil {
+			return 0, fmt.Errorf("%s: %w", fn, err)
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return 0, err
+	}
+
+	dp, dt := periods-lw.periods, throttled-lw.throttled
+	lw.periods, lw.throttled = periods, throttled
+	if dp <= 0 || dt < 0 {
+		return 0, nil
+	}
+	return float64(dt) / float64(dp) * 100, nil
+}
+
+// readFields returns the key=value pairs of the line in fn starting with prefix.
+func readFields(fn string, prefix string) (map[string]string, error) {
+
+	f, err := os.Open(fn)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		fs := strings.Fields(sc.Text())
+		if len(fs) == 0 || fs[0] != prefix {
+			continue
+		}
+		kv := make(map[string]string, len(fs)-1)
+		for _, v := range fs[1:] {
+			if k, v, ok := strings.Cut(v, "="); ok {
+				kv[k] = v
+			}
+		}
+		return kv, nil
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+	return nil, fmt.Errorf("%s: no %q entry", fn, prefix)
+}
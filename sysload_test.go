@@ -0,0 +1,170 @@
+package grmgr
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// fakeProc writes proc and cgroup files under a temporary root.
+type fakeProc struct {
+	t    *testing.T
+	root string
+}
+
+func newFakeProc(t *testing.T) *fakeProc {
+	root := t.TempDir()
+	for _, d := range []string{"proc/pressure", "cgroup"} {
+		if err := os.MkdirAll(filepath.Join(root, d), 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	fp := &fakeProc{t: t, root: root}
+	fp.set(0, 0, 0)
+	return fp
+}
+
+func (fp *fakeProc) write(name, content string) {
+	if err := os.WriteFile(filepath.Join(fp.root, name), []byte(content), 0o644); err != nil {
+		fp.t.Fatal(err)
+	}
+}
+
+func (fp *fakeProc) set(load float64, cpuPressure float64, periods int64) {
+	fp.write("proc/loadavg", strconv.FormatFloat(load, 'f', 2, 64)+" 0.50 0.40 1/123 4567\n")
+	fp.write("proc/pressure/cpu", "some avg10="+strconv.FormatFloat(cpuPressure, 'f', 2, 64)+" avg60=0.00 avg300=0.00 total=0\n"+
+		"full avg10=0.00 avg60=0.00 avg300=0.00 total=0\n")
+	fp.write("cgroup/cpu.stat", "usage_usec 1000\nnr_periods "+strconv.FormatInt(periods*2, 10)+"\nnr_throttled "+strconv.FormatInt(periods, 10)+"\nthrottled_usec 10\n")
+}
+
+func TestLoadWatcherCheck(t *testing.T) {
+	fp := newFakeProc(t)
+	lw, err := newLoadWatcher(LoadWatch{
+		ProcRoot:     filepath.Join(fp.root, "proc"),
+		CgroupRoot:   filepath.Join(fp.root, "cgroup"),
+		LoadPerCPU:   2,
+		CPUPressure:  40,
+		CPUThrottled: 60,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ncpu := float64(runtime.NumCPU())
+
+	for i, tc := range []struct {
+		load, pressure      float64
+		periods             int64
+		overload, recovered bool
+	}{
+		{0.1, 1, 0, false, true},
+		{3 * ncpu, 1, 0, true, false},      // load
+		{0.1, 50, 0, true, false},          // cpu pressure
+		{0.1, 35, 0, false, false},         // between recovery level and threshold
+		{0.1, 1, 100, false, false},        // half of periods throttled: 50% is above 0.8*60
+		{0.1, 1, 100, false, true},         // no change in throttled periods
+		{1.9 * ncpu, 1, 100, false, false}, // load above recovery level
+	} {
+		fp.set(tc.load, tc.pressure, tc.periods)
+		overload, recovered, err := lw.check()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if overload != tc.overload || recovered != tc.recovered {
+			t.Errorf("%d: expected overload %t recovered %t got %t %t", i, tc.overload, tc.recovered, overload, recovered)
+		}
+	}
+}
+
+func TestWatchSystemLoad(t *testing.T) {
+	fp := newFakeProc(t)
+	m, _ := startManager(t)
+	l, _ := m.NewConfig("load", 4, 2, 2, 1, "0s")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	done := make(chan error, 1)
+	go func() {
+		done <- m.WatchSystemLoad(ctx, LoadWatch{Interval: 10 * time.Millisecond, ProcRoot: filepath.Join(fp.root, "proc"), CPUPressure: 40})
+	}()
+
+	fp.set(0, 90, 0)
+	time.Sleep(25 * time.Millisecond)
+	fp.set(0, 1, 0)
+
+	// wait for the ceiling to return to 4 once the host recovers
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		n := slots(l)
+		if n == 4 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("expected ceiling to recover to 4 got %d", n)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	cancel()
+	if err := <-done; err != context.Canceled {
+		t.Errorf("expected context.Canceled got %v", err)
+	}
+}
+
+// waitSlots waits up to 5s for l to have n slots.
+func waitSlots(t *testing.T, l *Limiter, n int) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		got := slots(l)
+		if got == n {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("expected ceiling %d got %d", n, got)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestWatchSystemLoadHold(t *testing.T) {
+	fp := newFakeProc(t)
+	m, _ := startManager(t)
+	l, _ := m.NewConfig("load-hold", 8, 2, 2, 6, "30ms")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go m.WatchSystemLoad(ctx, LoadWatch{Interval: 10 * time.Millisecond, ProcRoot: filepath.Join(fp.root, "proc"), CPUPressure: 40})
+
+	// one throttle down is applied, those that follow are rejected by the hold period or change nothing at the minimum
+	fp.set(0, 90, 0)
+	waitSlots(t, l, 6)
+	time.Sleep(150 * time.Millisecond)
+	// neither overloaded nor recovered
+	fp.set(0, 35, 0)
+	time.Sleep(50 * time.Millisecond)
+	if err := l.SetBounds(2, 8); err != nil {
+		t.Fatal(err)
+	}
+	if err := l.SetCeiling(4); err != nil {
+		t.Fatal(err)
+	}
+
+	// recovery reverses the applied throttle down only, leaving the manual reduction in place
+	fp.set(0, 1, 0)
+	waitSlots(t, l, 6)
+	time.Sleep(200 * time.Millisecond)
+	if n := slots(l); n != 6 {
+		t.Errorf("expected ceiling to stay at 6 got %d", n)
+	}
+}
+
+func TestWatchSystemLoadNoThresholds(t *testing.T) {
+	m := NewManager()
+	if err := m.WatchSystemLoad(context.Background(), LoadWatch{}); err == nil {
+		t.Error("expected error with no thresholds")
+	}
+}
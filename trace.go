@@ -0,0 +1,29 @@
+package grmgr
+
+import (
+	"context"
+)
+
+// WaitTracer is notified when a routine waits in Control(), ControlContext() etc. for a slot,
+// e.g. to record a trace span. StartWait is called on the waiting routine with the caller's context
+// (context.Background() for Control()) and returns a function called with the outcome when the
+// wait ends, nil if a slot was granted. Immediate grants on the fast path are not traced.
+type WaitTracer interface {
+	StartWait(ctx context.Context, limiter Routine, n int) (end func(err error))
+}
+
+type tracerBox struct {
+	t WaitTracer
+}
+
+// SetWaitTracer installs t as the WaitTracer of m. Pass nil to remove it.
+func (m *Manager) SetWaitTracer(t WaitTracer) {
+	m.tracer.Store(tracerBox{t})
+}
+
+func (m *Manager) waitTracer() WaitTracer {
+	if b, ok := m.tracer.Load().(tracerBox); ok {
+		return b.t
+	}
+	return nil
+}
@@ -0,0 +1,40 @@
+package grmgr
+
+type weightedEnd struct {
+	r Routine
+	n int
+}
+
+// ControlN is Control() for a task that consumes n units of the limiter's ceiling, e.g. a heavy task
+// may claim several units of the dop while light tasks claim one. A weight greater than the
+// current ceiling is granted only when the limiter is otherwise idle.
+// The task must finish with DoneN(n) using the same weight. A weight less than 1 is logged and
+// not granted.
+func (l *Limiter) ControlN(n int) {
+	if n < 1 {
+		logErr(&ControlError{Limiter: l.r, Err: ErrWeight})
+		return
+	}
+	l.controlWait(n, 0)
+}
+
+// DoneN releases the n units claimed by ControlN(n).
+func (l *Limiter) DoneN(n int) {
+	if n < 1 {
+		logErr(&ControlError{Limiter: l.r, Err: ErrWeight})
+		return
+	}
+	if n == 1 {
+		l.Done()
+		return
+	}
+	if l.fast {
+		l.fastDone(n)
+		return
+	}
+	select {
+	case l.m.rEndNCh <- weightedEnd{r: l.r, n: n}:
+	case <-l.m.powerOffCh:
+		l.wg.Done()
+	}
+}
@@ -0,0 +1,135 @@
+package grmgr
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// runTasks runs n tasks of the given weight against l and returns the peak units in use.
+func runTasks(l *Limiter, n int, weight func(i int) int) int64 {
+
+	var cur, peak int64
+	for i := 0; i < n; i++ {
+		w := weight(i)
+		l.ControlN(w)
+		go func() {
+			v := atomic.AddInt64(&cur, int64(w))
+			for {
+				p := atomic.LoadInt64(&peak)
+				if v <= p || atomic.CompareAndSwapInt64(&peak, p, v) {
+					break
+				}
+			}
+			time.Sleep(time.Millisecond)
+			atomic.AddInt64(&cur, -int64(w))
+			l.DoneN(w)
+		}()
+	}
+	l.Wait()
+	return peak
+}
+
+func TestControlN(t *testing.T) {
+	m, _ := startManager(t)
+	l := m.New("weighted", 4)
+
+	peak := runTasks(l, 100, func(i int) int {
+		if i%10 == 0 {
+			return 3
+		}
+		return 1
+	})
+	if peak > 4 {
+		t.Errorf("expected at most 4 units in use got %d", peak)
+	}
+}
+
+func TestControlNNotStarved(t *testing.T) {
+	m, _ := startManager(t)
+	l := m.New("starve", 2)
+
+	// keep the limiter saturated with light tasks while a heavy task waits
+	var wg sync.WaitGroup
+	stop := make(chan struct{})
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-stop:
+					return
+				default:
+				}
+				l.Control()
+				time.Sleep(time.Millisecond)
+				l.Done()
+			}
+		}()
+	}
+
+	granted := make(chan struct{})
+	go func() {
+		l.ControlN(2)
+		close(granted)
+		l.DoneN(2)
+	}()
+
+	select {
+	case <-granted:
+	case <-time.After(5 * time.Second):
+		t.Error("weighted ask starved by single unit asks")
+	}
+	close(stop)
+	wg.Wait()
+	l.Wait()
+}
+
+func TestControlNInvalidWeight(t *testing.T) {
+	m, _ := startManager(t)
+	l := m.New("invalid", 1)
+
+	// weights below 1 neither claim nor release units
+	l.ControlN(0)
+	l.ControlN(-2)
+	l.Control()
+	l.DoneN(0)
+	l.DoneN(-2)
+	if l.TryControl() {
+		t.Fatal("expected no slot at ceiling")
+	}
+	l.DoneN(1)
+	if !l.TryControl() {
+		t.Fatal("expected slot after DoneN(1)")
+	}
+	l.Done()
+	l.Wait()
+}
+
+func TestTryControlQueuedHead(t *testing.T) {
+	m, _ := startManager(t)
+	l := m.New("try-queued", 2)
+
+	l.Control()
+	granted := make(chan struct{})
+	go func() {
+		l.ControlN(2)
+		close(granted)
+	}()
+	time.Sleep(20 * time.Millisecond)
+
+	// the free unit is held back for the queued weighted ask
+	if l.TryControl() {
+		t.Fatal("expected TryControl not to jump ahead of a queued waiter")
+	}
+	l.Done()
+	<-granted
+	l.DoneN(2)
+	if !l.TryControl() {
+		t.Fatal("expected slot once the queue is empty")
+	}
+	l.Done()
+	l.Wait()
+}